@@ -7,11 +7,28 @@ package tree
  */
 
 // 该前缀树实现的核心代码:
-// addRoute (第89行)
-// insertChild (第356行)
+// addRoute (第251行)
+// insertChild (第552行)
 
 import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/url"
+	pathpkg "path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -28,14 +45,88 @@ const (
 )
 
 type node struct {
-	path      string        //当前结点储存的路径
-	indices   string        //当前结点所有子结点的path首字符
-	wildChild bool          //当前结点的子结点是否为模糊结点(带":"或"*")
-	nType     nodeType      //当前结点的类型
-	priority  uint32        //当前结点的权重
-	children  []*node       //当前结点的孩子结点列表
-	handlers  HandlersChain //当前结点对应的处理函数(若不是完整路径，则为nil)
-	fullPath  string        //从根结点到当前结点的完整路径
+	path           string            //当前结点储存的路径
+	indices        string            //当前结点所有子结点的path首字符
+	wildChild      bool              //当前结点的子结点是否为模糊结点(带":"或"*")
+	nType          nodeType          //当前结点的类型
+	priority       uint32            //当前结点的权重
+	children       []*node           //当前结点的孩子结点列表
+	handlers       HandlersChain     //当前结点对应的处理函数(若不是完整路径，则为nil)
+	fullPath       string            //从根结点到当前结点的完整路径
+	opts           *Options          //整棵树的配置项(只有根结点才会设置)
+	timeout        time.Duration     //当前路由处理函数的超时时间(0表示未设置)
+	lazy           *lazyHandlers     //延迟构造的处理函数(通过AddLazy注册时才会设置)
+	chains         []HandlersChain   //*类型路由依次尝试的多组处理函数(通过AddCatchAllChain注册时才会设置)
+	preferWildcard bool              //匹配时是否先尝试通配子结点再尝试静态子结点
+	exact          bool              //是否只允许精确匹配(通过AddExact注册时才会设置)
+	seq            uint32            //当前结点是其父结点的第几个被添加的子结点(从0开始)
+	unbound        bool              //通过GobDecode恢复的路由结点 尚未通过Bind绑定处理函数
+	rateLimit      int               //当前路由每秒允许的请求数(0表示不限制)
+	pinned         bool              //权重是否固定(通过AddPinned注册时才会设置)
+	minLen, maxLen int               //参数结点允许的参数值长度范围(0表示不限制)
+	fallback       *depthFallback    //当前结点下一层的兜底处理函数(通过AddDepthFallback注册时才会设置)
+	trimSuffix     string            //*类型参数值需要去掉的后缀(通过SetCatchAllSuffix设置)
+	hits           uint32            //开启CountHits后当前路由被匹配到的次数
+	paramType      string            //参数值的类型(通过AddTypedRoute声明时才会设置)
+	constraint     func(string) bool //参数值需要满足的条件(通过AddRouteConstrained注册时才会设置)
+	cache          CachePolicy       //当前路由的缓存策略(通过AddRouteWithCache注册时才会设置)
+	handlerName    string            //处理函数的名称(通过AddNamed注册时才会设置)
+	tailTemplate   []string          //将*参数值继续拆分为多个参数的模板(按"/"拆分 通过SetCatchAllTemplate设置)
+	tsrPolicy      TSRPolicy         //以该结点为前缀的子树的末尾'/'策略(通过SetTrailingSlashPolicy设置)
+}
+
+// Options 整棵树范围内的配置项
+// 通过根结点的SetOptions设置 查找/插入时从根结点读取
+type Options struct {
+	// 为true时 ":"参数捕获到空值则视为匹配失败
+	// 注意 /user/:name 本身就不会匹配 /user/
+	// 空值只会出现在类似 /user/:name/profile 匹配 /user//profile 的情况
+	RejectEmptyParams bool
+
+	// 为true时 记录每次addRoute的耗时 可通过SlowestInsertions查看
+	RecordInsertions bool
+
+	// 为true时 重复注册同一路由不再panic 而是将新的处理函数追加到原有处理函数之后
+	AppendOnDuplicate bool
+
+	// 路由挂载的基础路径(如 /app) 查找时先从请求路径中去掉它再匹配 路由注册时不需要包含它
+	// 请求路径不以它开头时视为匹配失败 请求路径恰好为基础路径时按 / 匹配
+	BasePath string
+
+	// 每个结点最多允许的子结点个数 0表示不限制
+	// addRoute需要让某个结点超出该数量时会panic 避免出现子结点过多、逐个比较indices变慢的结点
+	MaxChildren int
+
+	// 为true时 getValue每次匹配成功都会原子地增加路由结点的命中次数
+	// 子结点的顺序不会随之立即调整 需要定期调用Rebalance按命中次数重新排序
+	CountHits bool
+
+	// 为true时 getValue按AddTypedRoute声明的类型校验参数值 不符合时该参数分支匹配失败
+	ValidateParamTypes bool
+
+	// 比较结点path与请求路径中对应部分的函数 为nil时逐字节比较(默认)
+	// 可用于不区分大小写等宽松匹配 两个参数的字节长度总是相同
+	// 设置后getValue也用它比较indices中的首字符 可能有多个静态子结点匹配 失败时依次回退尝试
+	// 兜底处理函数、通配子结点优先等其他行为不变 AddExact注册的路由仍然要求逐字节相同
+	// FrozenTree不使用该选项
+	SegmentEqual func(a, b string) bool
+
+	// 通过其他方法注册到整棵树上的内容 SetOptions时会保留
+	registry
+}
+
+// 未设置配置项时使用的默认值 只读
+var defaultOptions Options
+
+// 通过SetParamTransformer、Use等方法注册到整棵树上的内容
+type registry struct {
+	paramTransformers map[string]func(string) string //按参数名注册的参数值转换函数
+	middleware        map[string]HandlersChain       //按路径前缀注册的中间件
+	insertions        []InsertionTiming              //开启RecordInsertions后记录的插入耗时
+	trace             *[]TraceEvent                  //addRouteTraced执行期间记录结构变化的位置
+	subscribers       *subscribers                   //通过Subscribe订阅路由变化的调用方
+	paramEnums        map[string]map[string]struct{} //按参数名注册的允许取值
+	tsrPolicies       bool                           //是否有子树设置了末尾'/'策略
 }
 
 //min of a and b
@@ -54,13 +145,27 @@ func BytesToString(b []byte) string {
 // Increments priority of the given child and reorders if necessary
 // 处理、调整子结点们的优先级(非核心功能，仅为增强匹配速率)
 func (n *node) incrementChildPrio(pos int) int {
+	// 权重固定的结点不参与调整
+	if n.children[pos].pinned {
+		return pos
+	}
+	n.children[pos].priority++
+	return n.reorderChild(pos)
+}
+
+// 按权重将pos处的子结点向前移动到合适位置 并同步调整indices
+// 权重相同时按首字符(即indices中对应的字符)从小到大排列
+// 这样子结点的顺序只取决于权重 与插入顺序无关
+// 返回子结点移动后的新位置
+func (n *node) reorderChild(pos int) int {
 	cs := n.children
-	cs[pos].priority++
 	prio := cs[pos].priority
+	c := n.indices[pos]
 
 	// Adjust position (move to front)
 	newPos := pos
-	for ; newPos > 0 && cs[newPos-1].priority < prio; newPos-- {
+	for ; newPos > 0 && (cs[newPos-1].priority < prio ||
+		cs[newPos-1].priority == prio && n.indices[newPos-1] > c); newPos-- {
 		// Swap node positions
 		cs[newPos-1], cs[newPos] = cs[newPos], cs[newPos-1]
 	}
@@ -85,16 +190,98 @@ func longestCommonPrefix(a, b string) int {
 	return i
 }
 
+// 在第i个字节处把结点分裂成两部分(addRoute中的第一种情况)
+// priority为新子结点的权重 fullPath为分裂后原结点(公共前缀)的全路径
+func (n *node) split(i int, priority uint32, fullPath string) {
+	// 要把当前结点分裂成两部分
+	// 第一部分是公共前缀 第二部分是剩余子串
+	// 第一部分连接第二部分(是第二部分的父节点)
+	// 第二部分要继续连接子结点们
+	// 所以新建一个结点用来保存第二部分
+	// 新结点继承了原结点的大部分属性
+	child := node{
+		path:           n.path[i:], //新结点保存的是 原结点公共前缀后的部分
+		wildChild:      n.wildChild,
+		indices:        n.indices,
+		children:       n.children,
+		handlers:       n.handlers,
+		priority:       priority,
+		fullPath:       n.fullPath,
+		timeout:        n.timeout,
+		lazy:           n.lazy,
+		preferWildcard: n.preferWildcard,
+		exact:          n.exact,
+		rateLimit:      n.rateLimit,
+		pinned:         n.pinned,
+		unbound:        n.unbound,
+		fallback:       n.fallback,
+		hits:           n.hits,
+		cache:          n.cache,
+		handlerName:    n.handlerName,
+		tsrPolicy:      n.tsrPolicy,
+	}
+
+	// 现在原结点的孩子结点变成了新结点
+	n.children = []*node{&child}
+	// 现在原结点保存新结点的首字母
+	n.indices = BytesToString([]byte{n.path[i]})
+	// 现在原结点的path变成了公共前缀
+	n.path = n.path[:i]
+	// 现在原结点的handlers等属性都移到了新结点上
+	n.handlers = nil
+	n.timeout = 0
+	n.lazy = nil
+	n.exact = false
+	n.rateLimit = 0
+	n.pinned = false
+	n.unbound = false
+	n.fallback = nil
+	n.hits = 0
+	n.cache = CachePolicy{}
+	n.handlerName = ""
+	n.tsrPolicy = TSRInherit
+	// 现在原结点的子结点(原结点的第二部分)一定不是通配结点
+	// 因为路径中间不能出现":"和"*"
+	n.wildChild = false
+	n.preferWildcard = false
+	n.fullPath = fullPath
+}
+
 //添加路由
 func (n *node) addRoute(path string, handlers HandlersChain) {
 	//传入的路径是全路径
 	fullPath := path
-	n.priority++
+	// 权重固定的结点不参与调整(与incrementChildPrio一致)
+	if !n.pinned {
+		n.priority++
+	}
+
+	// 整棵树的配置项保存在根结点上 下面n会不断切换到子结点 所以先取出来
+	opts := n.opts
+	if opts == nil {
+		opts = &defaultOptions
+	}
+
+	// 按配置记录本次插入的耗时
+	if opts.RecordInsertions {
+		defer opts.recordInsertion(fullPath, time.Now())
+	}
+
+	// 插入成功后通知订阅者 插入失败(panic)时不通知
+	if opts.subscribers != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				panic(r)
+			}
+			opts.subscribers.notify(RouteEvent{Type: RouteAdded, Path: fullPath})
+		}()
+	}
 
 	// 如果是空树那么当前结点就变成根结点
 	if len(n.path) == 0 && len(n.children) == 0 {
 		n.insertChild(path, fullPath, handlers)
 		n.nType = root
+		opts.traceEvent("insert", "", "empty tree, insert %q as root", path)
 		return
 	}
 
@@ -126,36 +313,19 @@ walk:
 		// 这种情况并不对新结点执行插入操作
 		// 只对n执行分裂的操作
 		if i < len(n.path) {
+			opts.traceEvent("split", n.path, "split node %q at prefix len %d", n.path, i)
 
 			// 要把当前结点分裂成两部分
 			// 第一部分是公共前缀 第二部分是剩余子串
-			// 第一部分连接第二部分(是第二部分的父节点)
-			// 第二部分要继续连接子结点们
-			// 所以新建一个结点用来保存第二部分
-			// 新结点继承了原结点的大部分属性
-			child := node{
-				path:      n.path[i:], //新结点保存的是 原结点公共前缀后的部分
-				wildChild: n.wildChild,
-				indices:   n.indices,
-				children:  n.children,
-				handlers:  n.handlers,
-				priority:  n.priority - 1, //由于变成子结点了，相当于权重降了一级
-				fullPath:  n.fullPath,
-			}
-
-			// 现在原结点的孩子结点变成了新结点
-			n.children = []*node{&child}
-			// 现在原结点保存新结点的首字母
-			n.indices = BytesToString([]byte{n.path[i]})
-			// 现在原结点的path变成了公共前缀
-			n.path = path[:i]
-			// 现在原结点的handlers先定义成nil 最终会统一赋值
-			n.handlers = nil
-			// 现在原结点的子结点(原结点的第二部分)一定不是通配结点
-			// 因为路径中间不能出现":"和"*"
-			n.wildChild = false
+			// 由于变成子结点了，相当于权重降了一级
+			// 固定权重的结点进入时没有增加权重 新结点保持固定的权重 前缀结点补上本次插入
+			priority := n.priority - 1
+			if n.pinned {
+				priority = n.priority
+				n.priority++
+			}
 			// 现在原结点的全路径变成了公共前缀最后一个字符前的全路径
-			n.fullPath = fullPath[:parentFullPathIndex+i]
+			n.split(i, priority, fullPath[:parentFullPathIndex+i])
 		}
 
 		// 如果公共前缀长度小于path长度
@@ -164,7 +334,10 @@ walk:
 		// n.path一定是path是子串(否则不会进入第二种情况)
 		// 即n.path是path的前缀
 		// 这里要考虑参数结点的各种情况
-		if i < len(path) {
+		// 例外: 已有*类型路由(如 /files/*path)时注册段根路由(如 /files/)
+		// 此时n为*类型的前置结点(path为空) 与下面的"添加处理函数"相同 处理函数直接保存在n上
+		segmentRoot := n.nType == catchAll && len(n.path) == 0 && path[i:] == "/"
+		if i < len(path) && !segmentRoot {
 			// path变成公共前缀后的部分
 			path = path[i:]
 			// 取path第一个字符
@@ -186,7 +359,10 @@ walk:
 				parentFullPathIndex += len(n.path)
 				//移到下一级结点后，继续循环
 				n = n.children[0]
-				n.priority++
+				if !n.pinned {
+					n.priority++
+				}
+				opts.traceEvent("walk", n.path, "walk into child %q of param", n.path)
 				continue walk
 			}
 
@@ -204,9 +380,13 @@ walk:
 				if c == n.indices[i] {
 					parentFullPathIndex += len(n.path)
 					//先处理权重(非核心功能，仅为了优化匹配速率)
-					i = n.incrementChildPrio(i)
+					pos := n.incrementChildPrio(i)
+					if pos != i {
+						opts.traceEvent("reorder", n.path, "reorder priority at %q: child %q moved from %d to %d", n.path, n.children[pos].path, i, pos)
+					}
 					//然后path与该结点重新进行分裂合并，即重新循环
-					n = n.children[i]
+					n = n.children[pos]
+					opts.traceEvent("walk", n.path, "walk into child %q", n.path)
 					continue walk
 				}
 			}
@@ -220,6 +400,7 @@ walk:
 			// 将path插入为n的子结点
 			// 先处理最简单的情况
 			if c != ':' && c != '*' && n.nType != catchAll {
+				opts.checkFanout(n, fullPath[:parentFullPathIndex+len(n.path)], fullPath)
 				// []byte for proper unicode char conversion, see #65
 				// 拼接path第一个字符到n.indices中
 				n.indices += BytesToString([]byte{c})
@@ -227,7 +408,10 @@ walk:
 					fullPath: fullPath,
 				}
 				n.addChild(child)
-				n.incrementChildPrio(len(n.indices) - 1)
+				opts.traceEvent("insert", n.path, "add child with index %q under %q", c, n.path)
+				if pos := n.incrementChildPrio(len(n.indices) - 1); pos != len(n.indices)-1 {
+					opts.traceEvent("reorder", n.path, "reorder priority at %q: new child moved to %d", n.path, pos)
+				}
 				n = child
 			} else if n.wildChild {
 				// 不符合上面的条件 说明 path是通配结点||n是*类型的通配结点
@@ -240,7 +424,10 @@ walk:
 				// 那么切换到这个结点
 				// inserting a wildcard node, need to check if it conflicts with the existing wildcard
 				n = n.children[len(n.children)-1]
-				n.priority++
+				if !n.pinned {
+					n.priority++
+				}
+				opts.traceEvent("walk", n.path, "walk into wildcard child %q", n.path)
 
 				// Check if the wildcard matches
 				// 第一行是判断n.path是否为path的子串
@@ -295,7 +482,9 @@ walk:
 			// 至此 已经判断完了全部条件
 			// n已经是(可能经过了分裂合并)与path没有任何公共前缀的结点了
 			// 将path插入为n的子结点
+			opts.checkFanout(n, fullPath[:parentFullPathIndex+len(n.path)], fullPath)
 			n.insertChild(path, fullPath, handlers)
+			opts.traceEvent("insert", n.path, "insert remaining path %q", path)
 			return
 		}
 
@@ -310,10 +499,17 @@ walk:
 		// space继承了原本n的大多属性 包括handlers
 		// 这里要对handlers进行设置(因为/name)也有对应方法了
 		if n.handlers != nil {
+			// 按配置将新的处理函数追加到原有处理函数之后
+			if opts.AppendOnDuplicate {
+				n.handlers = combineHandlers(n.handlers, handlers)
+				opts.traceEvent("handle", n.path, "append handlers at %q", n.path)
+				return
+			}
 			panic("handlers are already registered for path '" + fullPath + "'")
 		}
 		n.handlers = handlers
 		n.fullPath = fullPath
+		opts.traceEvent("handle", n.path, "set handlers at %q", n.path)
 		return
 	}
 }
@@ -405,7 +601,9 @@ func (n *node) insertChild(path string, fullPath string, handlers HandlersChain)
 			n.wildChild = true
 			// 切换到通配结点
 			n = child
-			n.priority++
+			if !n.pinned {
+				n.priority++
+			}
 
 			// if the path doesn't end with the wildcard, then there
 			// will be another non-wildcard subpath starting with '/'
@@ -441,8 +639,14 @@ func (n *node) insertChild(path string, fullPath string, handlers HandlersChain)
 		// n.path不能以'/'结束
 		// 因为要在n.path下先插入 '/'结点
 		// 再在该'/'结点下插入全匹配结点
+		// 例外: n是没有子结点的段根路由(如先有 /files/ 再插入 /files/*path)
+		// 这时把n末尾的'/'拆出来作为*类型的前置结点 段根路由的处理函数也随之移过去
 		if len(n.path) > 0 && n.path[len(n.path)-1] == '/' {
-			panic("catch-all conflicts with existing handle for the path segment root in path '" + fullPath + "'")
+			if i > 0 || n.handlers == nil || len(n.children) > 0 {
+				panic("catch-all conflicts with existing handle for the path segment root in path '" + fullPath + "'")
+			}
+			n.insertCatchAllAfterSegmentRoot(wildcard, fullPath, handlers)
+			return
 		}
 
 		// currently fixed width 1 for '/'
@@ -471,7 +675,9 @@ func (n *node) insertChild(path string, fullPath string, handlers HandlersChain)
 		n.indices = string('/')
 		// 切换到子结点
 		n = child
-		n.priority++
+		if !n.pinned {
+			n.priority++
+		}
 
 		// second node: node holding the variable
 		// 创建第二个结点用来存放变量(全匹配结点)
@@ -500,6 +706,7 @@ func (n *node) insertChild(path string, fullPath string, handlers HandlersChain)
 // 只改变n.children的内容
 // 真正插入的操作是上面的insertChild
 func (n *node) addChild(child *node) {
+	child.seq = uint32(len(n.children))
 	if n.wildChild && len(n.children) > 0 {
 		wildcardChild := n.children[len(n.children)-1]
 		n.children = append(n.children[:len(n.children)-1], child, wildcardChild)
@@ -507,3 +714,3986 @@ func (n *node) addChild(child *node) {
 		n.children = append(n.children, child)
 	}
 }
+
+// 在段根路由n(如 /files/)之后插入*类型路由(如 /files/*path)
+// n末尾的'/'变为*类型的前置结点 段根路由的处理函数及其他路由属性保存在前置结点上
+// 这样getValue对 /files/ 使用段根路由 对 /files/x 使用*类型路由
+func (n *node) insertCatchAllAfterSegmentRoot(wildcard, fullPath string, handlers HandlersChain) {
+	leaf := &node{
+		path:     "/" + wildcard,
+		nType:    catchAll,
+		handlers: handlers,
+		priority: 1,
+		fullPath: fullPath,
+	}
+
+	// n的path只有'/'时 n本身就是父结点indices中'/'对应的子结点 直接转换为前置结点即可
+	if n.path == "/" && n.nType != root {
+		n.path = ""
+		n.nType = catchAll
+		n.wildChild = true
+		n.children = []*node{leaf}
+		return
+	}
+
+	segmentRoot := *n
+	segmentRoot.path = ""
+	segmentRoot.nType = catchAll
+	segmentRoot.wildChild = true
+	segmentRoot.children = []*node{leaf}
+	segmentRoot.seq = 0
+	segmentRoot.opts = nil
+
+	n.path = n.path[:len(n.path)-1]
+	n.indices = "/"
+	n.children = []*node{&segmentRoot}
+	n.handlers = nil
+	n.timeout = 0
+	n.lazy = nil
+	n.exact = false
+	n.rateLimit = 0
+	n.pinned = false
+	n.unbound = false
+	n.fallback = nil
+	n.hits = 0
+	n.cache = CachePolicy{}
+	n.handlerName = ""
+	n.fullPath = n.fullPath[:len(n.fullPath)-1]
+}
+
+// 段根路由保存在*类型的前置结点上 其完整路径比拼接得到的路径多一个'/'
+func segmentRootPath(n *node, prefix string) string {
+	if n.nType == catchAll && len(n.path) == 0 && n.handlers != nil {
+		return prefix + "/"
+	}
+	return prefix
+}
+
+// 根据预估的访问频率预先设置各路由的权重
+// weights的key为路由的完整路径(fullPath) value为对应权重
+// 未出现在weights中的路由权重记为1 通过AddPinned固定了权重的结点保持不变
+// 非路由结点(handlers为nil)的权重为其所有子结点权重之和
+// 设置完成后 每一层子结点都会按权重重新排序(与incrementChildPrio的调整逻辑一致)
+func (n *node) SeedPriorities(weights map[string]uint32) {
+	var prio uint32
+	if n.handlers != nil {
+		prio = 1
+		if w, ok := weights[n.fullPath]; ok {
+			prio = w
+		}
+	}
+	for _, child := range n.children {
+		child.SeedPriorities(weights)
+		prio += child.priority
+	}
+	if !n.pinned {
+		n.priority = prio
+	}
+
+	n.sortChildren()
+}
+
+// ValidatePath 可能返回的错误
+var (
+	ErrNoLeadingSlash        = errors.New("path must begin with '/'")
+	ErrMultipleWildcards     = errors.New("only one wildcard per path segment is allowed")
+	ErrUnnamedWildcard       = errors.New("wildcards must be named with a non-empty name")
+	ErrDuplicateParamName    = errors.New("duplicate wildcard name")
+	ErrCatchAllNotAtEnd      = errors.New("catch-all routes are only allowed at the end of the path")
+	ErrNoSlashBeforeCatchAll = errors.New("no / before catch-all")
+)
+
+// 在不改动树的前提下检查路由路径是否合法
+// 检查内容与insertChild中会panic的情况基本一致:
+// 1. 必须以'/'开头
+// 2. 每一段最多只能有一个通配符(借助findWildcard判断)
+// 3. 通配符必须有名字
+// 4. 同一路径中通配符不能重名
+// 5. *类型通配符只能出现在路径末尾 且前面必须是'/'
+// 返回的错误可以用errors.Is与上面的错误变量比较
+func ValidatePath(path string) error {
+	if len(path) == 0 || path[0] != '/' {
+		return fmt.Errorf("%w, has: '%s'", ErrNoLeadingSlash, path)
+	}
+
+	names := make(map[string]bool)
+	// rest为尚未检查的部分 offset为rest在path中的起始位置
+	rest, offset := path, 0
+	for {
+		wildcard, i, valid := findWildcard(rest)
+		if i < 0 {
+			return nil
+		}
+		if !valid {
+			return fmt.Errorf("%w, has: '%s' in path '%s'", ErrMultipleWildcards, wildcard, path)
+		}
+		if len(wildcard) < 2 {
+			return fmt.Errorf("%w in path '%s'", ErrUnnamedWildcard, path)
+		}
+		if names[wildcard[1:]] {
+			return fmt.Errorf("%w '%s' in path '%s'", ErrDuplicateParamName, wildcard[1:], path)
+		}
+		names[wildcard[1:]] = true
+
+		if wildcard[0] == '*' {
+			if offset+i+len(wildcard) != len(path) {
+				return fmt.Errorf("%w in path '%s'", ErrCatchAllNotAtEnd, path)
+			}
+			if path[offset+i-1] != '/' {
+				return fmt.Errorf("%w in path '%s'", ErrNoSlashBeforeCatchAll, path)
+			}
+		}
+
+		rest = rest[i+len(wildcard):]
+		offset += i + len(wildcard)
+	}
+}
+
+// Param is a single URL parameter, consisting of a key and a value.
+// 一个路由参数 Key为参数名(不含":"或"*") Value为匹配到的值
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params is a Param-slice, as returned by the router.
+// The slice is ordered, the first URL parameter is also the first slice value.
+// It is therefore safe to read values by the index.
+type Params []Param
+
+// Get returns the value of the first Param which key matches the given name and a boolean true.
+// If no matching Param is found, an empty string is returned and a boolean false .
+func (ps Params) Get(name string) (string, bool) {
+	for _, entry := range ps {
+		if entry.Key == name {
+			return entry.Value, true
+		}
+	}
+	return "", false
+}
+
+// ByName returns the value of the first Param which key matches the given name.
+// If no matching Param is found, an empty string is returned.
+func (ps Params) ByName(name string) (va string) {
+	va, _ = ps.Get(name)
+	return
+}
+
+// ByNameFold 与ByName相同 但参数名不区分大小写
+// 如路由中为 :name 时 ByNameFold("Name") 也能取到值
+func (ps Params) ByNameFold(name string) string {
+	for _, entry := range ps {
+		if strings.EqualFold(entry.Key, name) {
+			return entry.Value
+		}
+	}
+	return ""
+}
+
+// ByNameAll 按出现顺序返回名字为name的所有参数值 没有时返回nil
+// 同一个名字可能被多次捕获(如SetCatchAllTemplate拆分出的参数与路由中的参数同名)
+// ByName只返回第一个值
+func (ps Params) ByNameAll(name string) []string {
+	var values []string
+	for _, entry := range ps {
+		if entry.Key == name {
+			values = append(values, entry.Value)
+		}
+	}
+	return values
+}
+
+// nodeValue holds return values of (*Node).getValue method
+// getValue的返回值
+type nodeValue struct {
+	handlers HandlersChain //匹配到的处理函数
+	params   *Params       //匹配过程中捕获的参数
+	tsr      bool          //是否建议重定向到增加/去掉末尾'/'的路径
+	fullPath string        //匹配到的结点的完整路径(即注册时的路由模板)
+}
+
+// 匹配时经过的"既有静态子结点又有通配子结点"的结点
+// 静态子结点匹配失败后 需要回到这里改走通配子结点
+// 只记录结点本身和需要跳过的分支 不复制结点 回退记录不会分配内存
+type skippedNode struct {
+	path        string //回退后需要重新匹配的路径
+	node        *node  //回退到的结点
+	paramsCount int16  //回退时需要保留的参数个数
+	staticFrom  int    //回退后从indices的这个位置开始尝试静态子结点 等于len(indices)时不再尝试
+	noWild      bool   //回退后不再尝试通配子结点
+}
+
+// 将结点记录到回退列表中 容量不足时扩容
+// 采用先扩展长度再赋值的写法(而不是append) 调用方可以使用栈上的缓冲区而不会逃逸到堆上
+func pushSkippedNode(skippedNodes *[]skippedNode, skipped skippedNode) {
+	index := len(*skippedNodes)
+	if index == cap(*skippedNodes) {
+		grown := make([]skippedNode, index, 2*index+1)
+		copy(grown, *skippedNodes)
+		*skippedNodes = grown
+	}
+	*skippedNodes = (*skippedNodes)[:index+1]
+	(*skippedNodes)[index] = skipped
+}
+
+// Returns the handle registered with the given path (key). The values of
+// wildcards are saved to a map.
+// If no handle can be found, a TSR (trailing slash redirect) recommendation is
+// made if a handle exists with an extra (without the) trailing slash for the
+// given path.
+// 根据请求路径查找路由(与addRoute相对应的查找过程)
+// 优先匹配静态子结点 失败后再回退到通配子结点
+func (n *node) getValue(path string, params *Params, skippedNodes *[]skippedNode, unescape bool) (value nodeValue) {
+	return n.getValueWith(path, params, skippedNodes, unescape, nil)
+}
+
+// getValue的实现 st为nil时按配置项查找(设置了SegmentEqual时使用它比较路径)
+func (n *node) getValueWith(path string, params *Params, skippedNodes *[]skippedNode, unescape bool, st *lookupState) (value nodeValue) {
+	var globalParamsCount int16
+	opts := n.opts
+	if opts == nil {
+		opts = &defaultOptions
+	}
+	if opts.BasePath != "" {
+		var ok bool
+		if path, ok = stripBasePath(opts.BasePath, path); !ok {
+			return
+		}
+	}
+	if st == nil && opts.SegmentEqual != nil {
+		st = &lookupState{equal: opts.SegmentEqual, steps: -1}
+	}
+	// 下面n和path会不断变化 先记下根结点和完整的请求路径
+	top, reqPath := n, path
+	// 有子树设置了末尾'/'策略时 查找结束后按请求路径所在子树的策略调整结果
+	if opts.tsrPolicies && (st == nil || !st.retry) {
+		defer func() {
+			top.applyTSRPolicy(reqPath, &value, params, unescape, opts, st)
+		}()
+	}
+
+	// 最近经过的注册了兜底处理函数的结点 以及此时剩余的路径和已捕获的参数
+	var fallback *node
+	var fallbackPath string
+	var fallbackParams Params
+
+	// 从回退记录恢复时需要跳过的分支 只对恢复后的第一个结点有效
+	var staticFrom int
+	var noWild bool
+
+walk: // Outer loop for walking the tree
+	for {
+		// 限制了步数时 每进入一个结点(包括回退)消耗一步
+		if st != nil && st.steps >= 0 {
+			if st.steps == 0 {
+				st.exceeded = true
+				return nodeValue{}
+			}
+			st.steps--
+		}
+		prefix := n.path
+		// 进入该结点时剩余的路径 即回退到该结点后需要重新匹配的路径(用它而不是拼接prefix 避免分配内存)
+		rest := path
+		from, wildChild := staticFrom, n.wildChild && !noWild
+		staticFrom, noWild = 0, false
+		// 请求路径比当前结点的path长 说明还需要继续往下匹配
+		if len(path) > len(prefix) {
+			if st.same(prefix, path[:len(prefix)]) {
+				path = path[len(prefix):]
+
+				// 剩余路径只有一层时 记下兜底处理函数 所有分支都匹配失败后使用
+				if n.fallback != nil && strings.IndexByte(path, '/') < 0 {
+					fallback, fallbackPath = n, path
+					fallbackParams = nil
+					if value.params != nil {
+						fallbackParams = append(fallbackParams, *value.params...)
+					}
+				}
+
+				// 按配置优先尝试通配子结点
+				// 记下当前结点 通配子结点匹配失败后回退到这里改走静态子结点
+				if n.preferWildcard && wildChild && len(n.indices) > 0 {
+					pushSkippedNode(skippedNodes, skippedNode{
+						path:        rest,
+						node:        n,
+						paramsCount: globalParamsCount,
+						noWild:      true,
+					})
+				}
+
+				// Try all the non-wildcard children first by matching the indices
+				// 先按indices查找首字符相同的静态子结点
+				idxc := path[0]
+				for i := from; i < len(n.indices); i++ {
+					if n.preferWildcard && wildChild {
+						break
+					}
+					if n.indices[i] == idxc || st.custom() && st.equal(n.indices[i:i+1], path[:1]) {
+						// 如果同时还有通配子结点 先记下当前结点 以便静态子结点匹配失败后回退
+						// 回退后不会再次进入静态子结点 而是直接走通配子结点
+						// 使用自定义比较时后面的静态子结点也可能匹配 回退后从下一个静态子结点继续尝试
+						if st.custom() && (wildChild || i+1 < len(n.indices)) {
+							pushSkippedNode(skippedNodes, skippedNode{
+								path:        rest,
+								node:        n,
+								paramsCount: globalParamsCount,
+								staticFrom:  i + 1,
+								noWild:      !wildChild,
+							})
+						} else if wildChild {
+							pushSkippedNode(skippedNodes, skippedNode{
+								path:        rest,
+								node:        n,
+								paramsCount: globalParamsCount,
+								staticFrom:  len(n.indices),
+							})
+						}
+
+						n = n.children[i]
+						continue walk
+					}
+				}
+
+				if !wildChild {
+					// If the path at the end of the loop is not equal to '/' and the current node has no child nodes
+					// the current node needs to roll back to last valid skippedNode
+					if path != "/" {
+						for length := len(*skippedNodes); length > 0; length-- {
+							skippedNode := (*skippedNodes)[length-1]
+							*skippedNodes = (*skippedNodes)[:length-1]
+							if strings.HasSuffix(skippedNode.path, path) {
+								path = skippedNode.path
+								n = skippedNode.node
+								staticFrom, noWild = skippedNode.staticFrom, skippedNode.noWild
+								if value.params != nil {
+									*value.params = (*value.params)[:skippedNode.paramsCount]
+								}
+								globalParamsCount = skippedNode.paramsCount
+								continue walk
+							}
+						}
+					}
+
+					// Nothing found.
+					// We can recommend to redirect to the same URL without a
+					// trailing slash if a leaf exists for that path.
+					value.tsr = path == "/" && n.handlers != nil && !n.exact
+					break walk
+				}
+
+				// *类型的前置结点上注册了段根路由(如 /files/)时 剩余路径只有'/'的请求由它处理
+				if path == "/" && n.handlers != nil && n.nType == catchAll {
+					value.handlers = n.handlers
+					if n.lazy != nil {
+						value.handlers = n.lazy.get()
+					}
+					value.fullPath = n.fullPath
+					if opts.CountHits {
+						atomic.AddUint32(&n.hits, 1)
+					}
+					return
+				}
+
+				// Handle wildcard child, which is always at the end of the array
+				// 通配子结点总是位于children的最后(见addChild)
+				n = n.children[len(n.children)-1]
+				globalParamsCount++
+
+				switch n.nType {
+				case param:
+					// Find param end (either '/' or path end)
+					// 参数值到下一个'/'或路径末尾为止
+					end := 0
+					for end < len(path) && path[end] != '/' {
+						end++
+					}
+
+					// 参数值为空(按配置)、长度超出限制、不在允许的取值中或不符合声明的类型 视为匹配失败 尝试回退
+					if end == 0 && opts.RejectEmptyParams || !n.acceptsParam(path[:end]) || !opts.inParamEnum(n.path[1:], path[:end]) ||
+						opts.ValidateParamTypes && !validParamType(n.paramType, path[:end]) {
+						for length := len(*skippedNodes); length > 0; length-- {
+							skippedNode := (*skippedNodes)[length-1]
+							*skippedNodes = (*skippedNodes)[:length-1]
+							if strings.HasSuffix(skippedNode.path, path) {
+								path = skippedNode.path
+								n = skippedNode.node
+								staticFrom, noWild = skippedNode.staticFrom, skippedNode.noWild
+								if value.params != nil {
+									*value.params = (*value.params)[:skippedNode.paramsCount]
+								}
+								globalParamsCount = skippedNode.paramsCount
+								continue walk
+							}
+						}
+						break walk
+					}
+
+					// Save param value
+					if params != nil {
+						// Preallocate capacity if necessary
+						if cap(*params) < int(globalParamsCount) {
+							newParams := make(Params, len(*params), globalParamsCount)
+							copy(newParams, *params)
+							*params = newParams
+						}
+
+						if value.params == nil {
+							value.params = params
+						}
+						// Expand slice within preallocated capacity
+						i := len(*value.params)
+						*value.params = (*value.params)[:i+1]
+						val := path[:end]
+						if unescape {
+							if v, err := url.QueryUnescape(val); err == nil {
+								val = v
+							}
+						}
+						if transform := opts.paramTransformers[n.path[1:]]; transform != nil {
+							val = transform(val)
+						}
+						(*value.params)[i] = Param{
+							Key:   n.path[1:],
+							Value: val,
+						}
+					}
+
+					// we need to go deeper!
+					if end < len(path) {
+						if len(n.children) > 0 {
+							path = path[end:]
+							n = n.children[0]
+							continue walk
+						}
+
+						// ... but we can't
+						value.tsr = len(path) == end+1
+						// 回退到上一个可以改走其他分支的结点
+						if !value.tsr {
+							for length := len(*skippedNodes); length > 0; length-- {
+								skippedNode := (*skippedNodes)[length-1]
+								*skippedNodes = (*skippedNodes)[:length-1]
+								if strings.HasSuffix(skippedNode.path, path) {
+									path = skippedNode.path
+									n = skippedNode.node
+									staticFrom, noWild = skippedNode.staticFrom, skippedNode.noWild
+									if value.params != nil {
+										*value.params = (*value.params)[:skippedNode.paramsCount]
+									}
+									globalParamsCount = skippedNode.paramsCount
+									continue walk
+								}
+							}
+						}
+						break walk
+					}
+
+					if value.handlers = n.handlers; value.handlers != nil {
+						if n.lazy != nil {
+							value.handlers = n.lazy.get()
+						}
+						value.fullPath = n.fullPath
+						if opts.CountHits {
+							atomic.AddUint32(&n.hits, 1)
+						}
+						return
+					}
+					if len(n.children) == 1 {
+						// No handle found. Check if a handle for this path + a
+						// trailing slash exists for TSR recommendation
+						n = n.children[0]
+						value.tsr = (n.path == "/" && n.handlers != nil) || (n.path == "" && n.indices == "/") ||
+							(n.path == "" && n.nType == catchAll)
+					}
+					// 回退到上一个可以改走其他分支的结点
+					if !value.tsr {
+						for length := len(*skippedNodes); length > 0; length-- {
+							skippedNode := (*skippedNodes)[length-1]
+							*skippedNodes = (*skippedNodes)[:length-1]
+							if strings.HasSuffix(skippedNode.path, path) {
+								path = skippedNode.path
+								n = skippedNode.node
+								staticFrom, noWild = skippedNode.staticFrom, skippedNode.noWild
+								if value.params != nil {
+									*value.params = (*value.params)[:skippedNode.paramsCount]
+								}
+								globalParamsCount = skippedNode.paramsCount
+								continue walk
+							}
+						}
+					}
+					break walk
+
+				case catchAll:
+					// Save param value
+					// *类型结点匹配剩余的全部路径(包含开头的'/')
+					if params != nil {
+						// Preallocate capacity if necessary
+						if cap(*params) < int(globalParamsCount) {
+							newParams := make(Params, len(*params), globalParamsCount)
+							copy(newParams, *params)
+							*params = newParams
+						}
+
+						if value.params == nil {
+							value.params = params
+						}
+						// Expand slice within preallocated capacity
+						i := len(*value.params)
+						*value.params = (*value.params)[:i+1]
+						val := path
+						if unescape {
+							if v, err := url.QueryUnescape(path); err == nil {
+								val = v
+							}
+						}
+						val = strings.TrimSuffix(val, n.trimSuffix)
+						tail := val
+						if transform := opts.paramTransformers[n.path[2:]]; transform != nil {
+							val = transform(val)
+						}
+						(*value.params)[i] = Param{
+							Key:   n.path[2:],
+							Value: val,
+						}
+						if n.tailTemplate != nil {
+							appendTailParams(n.tailTemplate, tail, value.params)
+						}
+					}
+
+					value.handlers = n.handlers
+					if n.lazy != nil {
+						value.handlers = n.lazy.get()
+					}
+					value.fullPath = n.fullPath
+					if opts.CountHits {
+						atomic.AddUint32(&n.hits, 1)
+					}
+					return
+
+				default:
+					panic("invalid node type")
+				}
+			}
+		}
+
+		// 请求路径与当前结点的path相同 说明已经到达目标结点
+		if len(path) == len(prefix) && st.same(prefix, path) {
+			// 使用自定义比较时 只允许精确匹配的路由仍然要求请求路径逐字节相同
+			inexact := n.exact && st.custom() && reqPath != n.fullPath
+
+			// If the current path does not equal '/' and the node does not have a registered handle and the most recently matched node has a child node
+			// the current node needs to roll back to last valid skippedNode
+			if (n.handlers == nil || inexact) && path != "/" {
+				for length := len(*skippedNodes); length > 0; length-- {
+					skippedNode := (*skippedNodes)[length-1]
+					*skippedNodes = (*skippedNodes)[:length-1]
+					if strings.HasSuffix(skippedNode.path, path) {
+						path = skippedNode.path
+						n = skippedNode.node
+						staticFrom, noWild = skippedNode.staticFrom, skippedNode.noWild
+						if value.params != nil {
+							*value.params = (*value.params)[:skippedNode.paramsCount]
+						}
+						globalParamsCount = skippedNode.paramsCount
+						continue walk
+					}
+				}
+			}
+			if inexact {
+				break walk
+			}
+			// We should have reached the node containing the handle.
+			// Check if this node has a handle registered.
+			if value.handlers = n.handlers; value.handlers != nil {
+				if n.lazy != nil {
+					value.handlers = n.lazy.get()
+				}
+				value.fullPath = n.fullPath
+				if opts.CountHits {
+					atomic.AddUint32(&n.hits, 1)
+				}
+				return
+			}
+
+			// If there is no handle for this route, but this route has a
+			// wildcard child, there must be a handle for this path with an
+			// additional trailing slash
+			// 这两种情况建议的是去掉末尾'/'的路径 它对应的路由只允许精确匹配时不建议
+			if path == "/" && wildChild && n.nType != root {
+				value.tsr = !top.isExactRoute(reqPath[:len(reqPath)-1])
+				break walk
+			}
+
+			if path == "/" && n.nType == static {
+				value.tsr = !top.isExactRoute(reqPath[:len(reqPath)-1])
+				break walk
+			}
+
+			// No handle found. Check if a handle for this path + a
+			// trailing slash exists for trailing slash recommendation
+			for i := from; i < len(n.indices); i++ {
+				if n.indices[i] == '/' {
+					n = n.children[i]
+					value.tsr = (len(n.path) == 1 && n.handlers != nil && !n.exact) ||
+						(n.nType == catchAll && n.children[0].handlers != nil)
+					break walk
+				}
+			}
+
+			break walk
+		}
+
+		// Nothing found. We can recommend to redirect to the same URL with an
+		// extra trailing slash if a leaf exists for that path
+		value.tsr = path == "/" && !top.isExactRoute(reqPath[:len(reqPath)-1]) ||
+			(len(prefix) == len(path)+1 && prefix[len(path)] == '/' &&
+				st.same(prefix[:len(prefix)-1], path) && n.handlers != nil && !n.exact)
+
+		// roll back to last valid skippedNode
+		if !value.tsr && path != "/" {
+			for length := len(*skippedNodes); length > 0; length-- {
+				skippedNode := (*skippedNodes)[length-1]
+				*skippedNodes = (*skippedNodes)[:length-1]
+				if strings.HasSuffix(skippedNode.path, path) {
+					path = skippedNode.path
+					n = skippedNode.node
+					staticFrom, noWild = skippedNode.staticFrom, skippedNode.noWild
+					if value.params != nil {
+						*value.params = (*value.params)[:skippedNode.paramsCount]
+					}
+					globalParamsCount = skippedNode.paramsCount
+					continue walk
+				}
+			}
+		}
+
+		break walk
+	}
+
+	// 所有分支都匹配失败 且没有tsr建议时 使用前缀结点上注册的兜底处理函数
+	// 未匹配的那一层路径作为参数DepthFallbackParam
+	if fallback != nil && !value.tsr {
+		if params != nil {
+			val := fallbackPath
+			if unescape {
+				if v, err := url.QueryUnescape(val); err == nil {
+					val = v
+				}
+			}
+			*params = append(append((*params)[:0], fallbackParams...), Param{Key: DepthFallbackParam, Value: val})
+			value.params = params
+		}
+		value.handlers = fallback.fallback.handlers
+		value.fullPath = fallback.fallback.fullPath
+	}
+	return
+}
+
+// ParamSpan 参数值在原始请求路径中的位置
+// path[Start:End]即为该参数未经解码的原始值
+type ParamSpan struct {
+	Key   string
+	Start int
+	End   int
+}
+
+// VerboseValue getValue的详细版本返回值
+type VerboseValue struct {
+	Handlers  HandlersChain
+	Params    Params
+	TSR       bool
+	FullPath  string
+	Spans     []ParamSpan   //只有withSpans为true时才会填充
+	Timeout   time.Duration //匹配到的路由的超时时间(0表示未设置)
+	RateLimit int           //匹配到的路由每秒允许的请求数(0表示不限制)
+	Cache     CachePolicy   //匹配到的路由的缓存策略
+	//路由模板中去掉通配符后的静态部分 如 /user/:name/posts 为 /user//posts
+	//可以作为低基数的监控标签 按路由的静态结构分组
+	StaticSkeleton string
+}
+
+// 查找路由并返回详细的匹配信息
+// withSpans为true时 额外返回每个参数在原始请求路径中的起止位置
+// 便于调用方直接改写或高亮某一段路径 而不需要再次解析
+func (n *node) LookupVerbose(path string, unescape, withSpans bool) (value VerboseValue) {
+	var params Params
+	skippedNodes := make([]skippedNode, 0)
+	v := n.getValue(path, &params, &skippedNodes, unescape)
+
+	value.Handlers = v.handlers
+	value.TSR = v.tsr
+	value.FullPath = v.fullPath
+	if v.params != nil {
+		value.Params = *v.params
+	}
+	if v.handlers == nil {
+		return
+	}
+	value.StaticSkeleton = staticSkeleton(v.fullPath)
+	if withSpans {
+		// 去掉基础路径后再计算 位置仍然相对于原始请求路径
+		offset := 0
+		if n.opts != nil && n.opts.BasePath != "" {
+			path, _ = stripBasePath(n.opts.BasePath, path)
+			offset = len(strings.TrimSuffix(n.opts.BasePath, "/"))
+		}
+		value.Spans = paramSpans(v.fullPath, path)
+		for i := range value.Spans {
+			value.Spans[i].Start += offset
+			value.Spans[i].End += offset
+		}
+	}
+	if leaf := n.FindNode(v.fullPath); leaf != nil {
+		value.Timeout = leaf.timeout
+		value.RateLimit = leaf.rateLimit
+		value.Cache = leaf.cache
+	}
+	return
+}
+
+// 将路由模板中的通配符替换为空串 只保留静态部分
+func staticSkeleton(template string) string {
+	var sb strings.Builder
+	for {
+		wildcard, i, _ := findWildcard(template)
+		if i < 0 {
+			sb.WriteString(template)
+			return sb.String()
+		}
+		sb.WriteString(template[:i])
+		template = template[i+len(wildcard):]
+	}
+}
+
+// 对照路由模板与已经匹配成功的请求路径 计算每个参数值的起止位置
+// 静态部分两者逐字节相同 ":"参数匹配到下一个'/'为止 "*"参数匹配剩余全部路径
+func paramSpans(template, path string) []ParamSpan {
+	var spans []ParamSpan
+	ti, pi := 0, 0
+	for ti < len(template) {
+		switch template[ti] {
+		case ':':
+			end := strings.IndexByte(template[ti:], '/')
+			if end < 0 {
+				end = len(template) - ti
+			}
+			start := pi
+			for pi < len(path) && path[pi] != '/' {
+				pi++
+			}
+			spans = append(spans, ParamSpan{Key: template[ti+1 : ti+end], Start: start, End: pi})
+			ti += end
+		case '*':
+			// getValue中*类型参数的值包含其前面的'/'
+			spans = append(spans, ParamSpan{Key: template[ti+1:], Start: pi - 1, End: len(path)})
+			return spans
+		default:
+			ti++
+			pi++
+		}
+	}
+	return spans
+}
+
+// 合并结构完全相同的兄弟子树 返回合并掉的子树个数
+// 正常通过addRoute构建的树中 兄弟结点的首字符各不相同 不会出现相同的子树
+// 该方法用于手动修改树(如修改参数名)后 修复可能出现的重复结构
+// 被合并掉的子树的权重会累加到保留下来的子树上
+func (n *node) DedupeSubtrees() int {
+	merged := 0
+	for _, child := range n.children {
+		merged += child.DedupeSubtrees()
+	}
+
+	for i := 0; i < len(n.children); i++ {
+		for j := i + 1; j < len(n.children); {
+			if !sameSubtree(n.children[i], n.children[j]) {
+				j++
+				continue
+			}
+			n.children[i].priority += n.children[j].priority
+			if j < len(n.indices) {
+				n.indices = n.indices[:j] + n.indices[j+1:]
+			}
+			n.children = append(n.children[:j], n.children[j+1:]...)
+			merged++
+		}
+	}
+	return merged
+}
+
+// 递归判断两棵子树的结构是否相同(不比较权重)
+// 处理函数无法比较 只比较是否存在
+func sameSubtree(a, b *node) bool {
+	if a.path != b.path || a.indices != b.indices || a.nType != b.nType ||
+		a.wildChild != b.wildChild || a.fullPath != b.fullPath ||
+		(a.handlers == nil) != (b.handlers == nil) ||
+		len(a.children) != len(b.children) {
+		return false
+	}
+	for i := range a.children {
+		if !sameSubtree(a.children[i], b.children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// 设置整棵树的配置项 只应在根结点上调用
+// 之前通过SetParamTransformer、Use等方法注册的内容会被保留
+func (n *node) SetOptions(opts Options) {
+	if n.opts != nil {
+		opts.registry = n.opts.registry
+	}
+	n.opts = &opts
+}
+
+// 估算整棵树占用的内存(字节数)
+// 累加每个结点结构体本身的大小、path/indices/fullPath字符串的长度以及children切片的容量
+// 这只是一个估算值:
+// 不包含handlers闭包捕获的内容和opts等附加数据
+// 多个结点的字符串可能共享同一块底层内存(如fullPath都截取自注册时的路径) 这里会重复计算
+func (n *node) ApproxBytes() int64 {
+	size := int64(unsafe.Sizeof(*n)) +
+		int64(len(n.path)+len(n.indices)+len(n.fullPath)) +
+		int64(cap(n.children))*int64(unsafe.Sizeof(n))
+	for _, child := range n.children {
+		size += child.ApproxBytes()
+	}
+	return size
+}
+
+// 宽松查找: 优先精确匹配
+// 精确匹配失败但getValue建议重定向(tsr)时 直接返回增加/去掉末尾'/'后的路径对应的处理函数
+// 适用于调用方不想自己处理重定向 而是希望直接响应的情况
+func (n *node) LookupLenient(path string) (HandlersChain, Params, bool) {
+	value := n.LookupVerbose(path, false, false)
+	if value.Handlers != nil {
+		return value.Handlers, value.Params, true
+	}
+	if !value.TSR {
+		return nil, nil, false
+	}
+
+	if len(path) > 1 && path[len(path)-1] == '/' {
+		path = path[:len(path)-1]
+	} else {
+		path += "/"
+	}
+	value = n.LookupVerbose(path, false, false)
+	if value.Handlers == nil {
+		return nil, nil, false
+	}
+	return value.Handlers, value.Params, true
+}
+
+// 按字典序返回子树中所有已注册路由的完整路径
+func (n *node) routes() []string {
+	var list []string
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n.handlers != nil {
+			list = append(list, n.fullPath)
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+	sort.Strings(list)
+	return list
+}
+
+// 根据树中已注册的路由生成可以匹配成功的具体路径
+// 每个通配符依次替换为p1、p2...pN(N为maxPerParam)
+// 通过SetParamEnum限定了取值的参数改为使用允许的取值(按字典序 最多N个)
+// 一条路由中有多个通配符时 生成所有组合
+// 生成的路径都能匹配到某条路由 可以直接作为测试用例
+// 注意 参数的长度限制、类型和自定义约束不在考虑范围内 受它们限制的路由生成的路径可能匹配不到
+func (n *node) SamplePaths(maxPerParam int) []string {
+	if maxPerParam < 1 {
+		maxPerParam = 1
+	}
+	opts := n.opts
+	if opts == nil {
+		opts = &defaultOptions
+	}
+
+	var samples []string
+	for _, route := range n.routes() {
+		paths := []string{""}
+		for len(route) > 0 {
+			wildcard, i, _ := findWildcard(route)
+			if i < 0 {
+				for j := range paths {
+					paths[j] += route
+				}
+				break
+			}
+
+			values := opts.sampleValues(wildcard, maxPerParam)
+			expanded := make([]string, 0, len(paths)*len(values))
+			for _, p := range paths {
+				for _, v := range values {
+					expanded = append(expanded, p+route[:i]+v)
+				}
+			}
+			paths = expanded
+			route = route[i+len(wildcard):]
+		}
+		samples = append(samples, paths...)
+	}
+	return samples
+}
+
+// 按路由模板(而非请求路径)查找对应的结点 找不到时返回nil
+// 与getValue不同 这里的通配符按字面比较 如 /user/:name 只能找到 :name 结点
+func (n *node) FindNode(path string) *node {
+walk:
+	for {
+		if !strings.HasPrefix(path, n.path) {
+			return nil
+		}
+		path = path[len(n.path):]
+		if len(path) == 0 {
+			return n
+		}
+		// 段根路由(如 /files/)保存在*类型的前置结点上
+		if path == "/" && n.nType == catchAll && len(n.path) == 0 {
+			return n
+		}
+
+		for i, c := range []byte(n.indices) {
+			if c == path[0] {
+				n = n.children[i]
+				continue walk
+			}
+		}
+		if n.wildChild {
+			n = n.children[len(n.children)-1]
+			continue walk
+		}
+		// 参数结点后面的子结点没有记录在indices中
+		if n.nType == param && len(n.children) == 1 {
+			n = n.children[0]
+			continue walk
+		}
+		return nil
+	}
+}
+
+// 与FindNode相同地查找path 如果path在某个静态结点的path中间结束 就在该处分裂出一个结点并返回
+// 分裂不改变任何路由 两部分的权重都与原结点相同 path不在树中时返回nil
+func (n *node) splitAt(path string) *node {
+	fullPath := path
+walk:
+	for {
+		if !strings.HasPrefix(path, n.path) {
+			if len(path) == 0 || n.nType > root || !strings.HasPrefix(n.path, path) {
+				return nil
+			}
+			n.split(len(path), n.priority, fullPath)
+			return n
+		}
+		path = path[len(n.path):]
+		if len(path) == 0 {
+			return n
+		}
+
+		for i, c := range []byte(n.indices) {
+			if c == path[0] {
+				n = n.children[i]
+				continue walk
+			}
+		}
+		if n.wildChild {
+			n = n.children[len(n.children)-1]
+			continue walk
+		}
+		if n.nType == param && len(n.children) == 1 {
+			n = n.children[0]
+			continue walk
+		}
+		return nil
+	}
+}
+
+// 添加路由并设置其处理函数的超时时间
+// 超时时间可以通过LookupVerbose在匹配时取得 由外层中间件负责执行
+func (n *node) AddRouteWithTimeout(path string, handlers HandlersChain, timeout time.Duration) {
+	n.addRoute(path, handlers)
+	n.FindNode(path).timeout = timeout
+}
+
+// 统计每个*类型路由的作用范围内还注册了哪些更具体的路由
+// key为*类型路由的完整路径 value为共享其前缀("*"之前的部分)的其他路由
+// 这些路由会优先于*类型路由被匹配
+// 前缀本身的段根路由(如 /files/*path 的 /files/)保存在同一个结点上 不计入其中
+func (n *node) RoutesUnderCatchAll() map[string][]string {
+	routes := n.routes()
+	result := make(map[string][]string)
+	for _, route := range routes {
+		i := strings.IndexByte(route, '*')
+		if i < 0 {
+			continue
+		}
+		prefix := route[:i]
+		under := []string{}
+		for _, other := range routes {
+			if other != route && other != prefix && strings.HasPrefix(other, prefix) {
+				under = append(under, other)
+			}
+		}
+		result[route] = under
+	}
+	return result
+}
+
+// 延迟构造的处理函数
+// 第一次匹配到该路由时才调用factory 之后一直返回缓存的结果
+type lazyHandlers struct {
+	once     sync.Once
+	factory  func() HandlersChain
+	handlers HandlersChain
+}
+
+func (l *lazyHandlers) get() HandlersChain {
+	l.once.Do(func() {
+		l.handlers = l.factory()
+	})
+	return l.handlers
+}
+
+// 添加路由 但处理函数在第一次匹配到该路由时才通过factory构造
+// 适用于注册时还无法确定处理函数 或构造处理函数代价较大的情况
+// 结点上的handlers是一个占位函数 直接调用它也会先完成构造再执行
+func (n *node) AddLazy(path string, factory func() HandlersChain) {
+	lazy := &lazyHandlers{factory: factory}
+	n.addRoute(path, func() {
+		lazy.get()()
+	})
+	n.FindNode(path).lazy = lazy
+}
+
+// 前缀压缩效果统计
+type CompressionStats struct {
+	StoredBytes   int     //所有结点path长度之和
+	TemplateBytes int     //所有已注册路由完整路径长度之和
+	Ratio         float64 //StoredBytes / TemplateBytes 越小说明公共前缀共享得越多
+}
+
+// 统计前缀树相对于直接保存所有路由的压缩效果
+func (n *node) CompressionReport() CompressionStats {
+	var stats CompressionStats
+	var walk func(n *node)
+	walk = func(n *node) {
+		stats.StoredBytes += len(n.path)
+		if n.handlers != nil {
+			stats.TemplateBytes += len(n.fullPath)
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+	if stats.TemplateBytes > 0 {
+		stats.Ratio = float64(stats.StoredBytes) / float64(stats.TemplateBytes)
+	}
+	return stats
+}
+
+// 返回压缩比 即CompressionReport().Ratio
+func (n *node) CompressionRatio() float64 {
+	return n.CompressionReport().Ratio
+}
+
+// 结点类型的名称
+func (t nodeType) String() string {
+	switch t {
+	case static:
+		return "static"
+	case root:
+		return "root"
+	case param:
+		return "param"
+	case catchAll:
+		return "catchAll"
+	}
+	return "nodeType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// 导出JSON时单个结点的字段(不含子结点)
+type jsonNode struct {
+	Path        string `json:"path"`
+	Indices     string `json:"indices"`
+	WildChild   bool   `json:"wildChild"`
+	NType       string `json:"nType"`
+	Priority    uint32 `json:"priority"`
+	FullPath    string `json:"fullPath"`
+	HasHandlers bool   `json:"hasHandlers"`
+}
+
+// 以JSON格式将整棵树逐个结点写入w
+// 每个结点单独编码后立即写出 子结点放在"children"数组中
+// 不会在内存中构造完整的对象 适合导出非常大的路由表
+func (n *node) StreamJSON(w io.Writer) error {
+	data, err := json.Marshal(jsonNode{
+		Path:        n.path,
+		Indices:     n.indices,
+		WildChild:   n.wildChild,
+		NType:       n.nType.String(),
+		Priority:    n.priority,
+		FullPath:    n.fullPath,
+		HasHandlers: n.handlers != nil,
+	})
+	if err != nil {
+		return err
+	}
+
+	// 去掉结尾的'}' 接着写入children字段
+	if _, err = w.Write(data[:len(data)-1]); err != nil {
+		return err
+	}
+	if _, err = io.WriteString(w, `,"children":[`); err != nil {
+		return err
+	}
+	for i, child := range n.children {
+		if i > 0 {
+			if _, err = io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if err = child.StreamJSON(w); err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(w, "]}")
+	return err
+}
+
+// 添加一个*类型路由 并为其注册按顺序依次尝试的多组处理函数
+// 如 /docs/*path 先尝试返回具体文件 失败后再返回索引页
+// 普通查找返回第一组处理函数 LookupFallthrough返回全部
+func (n *node) AddCatchAllChain(path string, chains ...HandlersChain) {
+	if len(chains) == 0 {
+		panic("at least one handlers chain is required in path '" + path + "'")
+	}
+	if i := strings.LastIndexByte(path, '/'); i < 0 || len(path) < i+2 || path[i+1] != '*' {
+		panic("catch-all chains are only allowed for catch-all routes in path '" + path + "'")
+	}
+	n.addRoute(path, chains[0])
+	n.FindNode(path).chains = chains
+}
+
+// 查找路由并按顺序返回其全部处理函数
+// 通过AddCatchAllChain注册的路由返回注册时的多组处理函数 调用方依次尝试直到成功
+// 其他路由只返回唯一的一组处理函数
+func (n *node) LookupFallthrough(path string) ([]HandlersChain, Params, bool) {
+	value := n.LookupVerbose(path, false, false)
+	if value.Handlers == nil {
+		return nil, nil, false
+	}
+	if leaf := n.FindNode(value.FullPath); leaf != nil && leaf.chains != nil {
+		return leaf.chains, value.Params, true
+	}
+	return []HandlersChain{value.Handlers}, value.Params, true
+}
+
+// 找出权重最高的路由(权重相同时取完整路径字典序最小的)
+// 树中没有路由时返回空字符串和0
+func (n *node) HottestRoute() (fullPath string, priority uint32) {
+	found := false
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n.handlers != nil {
+			if !found || n.priority > priority ||
+				n.priority == priority && n.fullPath < fullPath {
+				fullPath, priority, found = n.fullPath, n.priority, true
+			}
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+	return
+}
+
+// 为名为name的参数注册转换函数 只应在根结点上调用
+// getValue保存参数值之前会先调用fn进行转换(如转小写、去空格等)
+// 匹配过程仍然使用原始路径 只有保存到Params中的值会被转换
+// 如果同时开启了unescape 会先解码再转换
+func (n *node) SetParamTransformer(name string, fn func(string) string) {
+	if n.opts == nil {
+		n.opts = &Options{}
+	}
+	if n.opts.paramTransformers == nil {
+		n.opts.paramTransformers = make(map[string]func(string) string)
+	}
+	n.opts.paramTransformers[name] = fn
+}
+
+// 找出所有"一条路由是另一条路由的前缀(按路径段)"的情况
+// 如 /user 与 /user/profile 返回 [/user, /user/profile]
+// 而 /user 与 /users 不算(不是在'/'处分隔的)
+// 这种嵌套本身是合法的 仅供检查接口设计时参考
+func (n *node) PrefixRoutes() [][2]string {
+	routes := n.routes()
+	var pairs [][2]string
+	for _, shorter := range routes {
+		for _, longer := range routes {
+			if len(longer) <= len(shorter) || !strings.HasPrefix(longer, shorter) {
+				continue
+			}
+			if shorter[len(shorter)-1] == '/' || longer[len(shorter)] == '/' {
+				pairs = append(pairs, [2]string{shorter, longer})
+			}
+		}
+	}
+	return pairs
+}
+
+// 将多组处理函数按顺序组合成一组 nil会被忽略
+func combineHandlers(chains ...HandlersChain) HandlersChain {
+	var combined []HandlersChain
+	for _, h := range chains {
+		if h != nil {
+			combined = append(combined, h)
+		}
+	}
+	switch len(combined) {
+	case 0:
+		return nil
+	case 1:
+		return combined[0]
+	}
+	return func() {
+		for _, h := range combined {
+			h()
+		}
+	}
+}
+
+// 为prefix开头的所有路由注册中间件(类似gin的路由组) 只应在根结点上调用
+// 同一前缀重复注册时 新的中间件追加在原有中间件之后
+func (n *node) Use(prefix string, middleware HandlersChain) {
+	if n.opts == nil {
+		n.opts = &Options{}
+	}
+	if n.opts.middleware == nil {
+		n.opts.middleware = make(map[string]HandlersChain)
+	}
+	n.opts.middleware[prefix] = combineHandlers(n.opts.middleware[prefix], middleware)
+}
+
+// 查找路由 并把所有前缀匹配的中间件(按前缀从短到长 即从根到叶子)与路由自身的处理函数组合成一组
+// 返回的处理函数可以直接执行
+func (n *node) Resolve(path string) (combined HandlersChain, params Params, ok bool) {
+	value := n.LookupVerbose(path, false, false)
+	if value.Handlers == nil {
+		return nil, nil, false
+	}
+	if n.opts == nil {
+		return value.Handlers, value.Params, true
+	}
+	return n.opts.withMiddleware(value.FullPath, value.Handlers), value.Params, true
+}
+
+// 将所有前缀匹配fullPath的中间件(按前缀从短到长)与handlers组合成一组
+func (r *registry) withMiddleware(fullPath string, handlers HandlersChain) HandlersChain {
+	if len(r.middleware) == 0 {
+		return handlers
+	}
+
+	var prefixes []string
+	for prefix := range r.middleware {
+		if strings.HasPrefix(fullPath, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) < len(prefixes[j])
+	})
+
+	chains := make([]HandlersChain, 0, len(prefixes)+1)
+	for _, prefix := range prefixes {
+		chains = append(chains, r.middleware[prefix])
+	}
+	chains = append(chains, handlers)
+	return combineHandlers(chains...)
+}
+
+// 检查树的结构是否满足addRoute/getValue所依赖的约定 返回发现的第一个问题
+// 1. indices中的每个字符与对应静态子结点path的首字符一致
+// 2. 有通配子结点(wildChild)时 它是最后一个子结点且类型为param或catchAll
+// 3. *类型的叶子结点没有子结点
+// 4. 有处理函数的结点 其fullPath与从根结点拼接得到的路径(即ComputeFullPath的结果)一致
+func (n *node) Validate() error {
+	return n.validate("")
+}
+
+func (n *node) validate(prefix string) error {
+	prefix += n.path
+	if n.handlers != nil && n.fullPath != segmentRootPath(n, prefix) {
+		return fmt.Errorf("node '%s' has fullPath '%s', expected '%s'", n.path, n.fullPath, segmentRootPath(n, prefix))
+	}
+
+	static := len(n.children)
+	if n.wildChild {
+		if static == 0 {
+			return fmt.Errorf("node '%s' is marked wildChild but has no children", prefix)
+		}
+		static--
+		if wild := n.children[static]; wild.nType != param && wild.nType != catchAll {
+			return fmt.Errorf("last child of node '%s' is not a wildcard", prefix)
+		}
+	} else if n.nType == param && len(n.indices) == 0 && len(n.children) <= 1 {
+		// 参数结点后面的子结点没有记录在indices中
+		static = 0
+	} else if n.nType == catchAll && len(n.path) > 0 && len(n.children) > 0 {
+		return fmt.Errorf("catch-all node '%s' must not have children", prefix)
+	}
+
+	if static != len(n.indices) {
+		return fmt.Errorf("node '%s' has %d indices but %d static children", prefix, len(n.indices), static)
+	}
+	for i := 0; i < static; i++ {
+		child := n.children[i]
+		first := child.path
+		// *类型的前置结点path为空 其首字符由唯一的子结点决定
+		if child.nType == catchAll && len(first) == 0 && len(child.children) == 1 {
+			first = child.children[0].path
+		}
+		if len(first) == 0 || first[0] != n.indices[i] {
+			return fmt.Errorf("index '%c' of node '%s' does not match child '%s'", n.indices[i], prefix, child.path)
+		}
+	}
+
+	for _, child := range n.children {
+		if err := child.validate(prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 根据路由列表重新构建一棵树
+// 路由先按字典序排序再依次插入 处理函数通过bind按路由获取
+// 单条路由的错误(不合法、冲突、重复等)会被收集起来 不会中断构建
+// 最后对整棵树执行Validate 结构错误同样追加到返回的错误列表中
+// 同一份路由列表总是得到相同的树
+func Rebuild(routes []string, bind func(string) HandlersChain) (*node, []error) {
+	sorted := append([]string(nil), routes...)
+	sort.Strings(sorted)
+
+	tree := &node{}
+	var errs []error
+	for _, route := range sorted {
+		if err := ValidatePath(route); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		handlers := bind(route)
+		if handlers == nil {
+			errs = append(errs, fmt.Errorf("no handlers bound for path '%s'", route))
+			continue
+		}
+		if err := tree.tryAddRoute(route, handlers); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := tree.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return tree, errs
+}
+
+// 添加路由 将addRoute中的panic转换为error返回
+func (n *node) tryAddRoute(path string, handlers HandlersChain) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	n.addRoute(path, handlers)
+	return nil
+}
+
+// 找出所有不包含任何路由的最大子树 返回其根结点对应的路径前缀
+// 通常是手动修改树之后遗留下来的分支 这里只做报告 不做清理
+func (n *node) DeadBranches() []string {
+	var dead []string
+	// 返回以n为根的子树中是否有路由
+	var walk func(n *node, prefix string) bool
+	walk = func(n *node, prefix string) bool {
+		prefix += n.path
+		live := n.handlers != nil
+		var deadChildren []string
+		for _, child := range n.children {
+			if walk(child, prefix) {
+				live = true
+			} else {
+				deadChildren = append(deadChildren, prefix+child.path)
+			}
+		}
+		// 只有当前子树有路由时 才报告其下没有路由的子结点
+		// 否则由更上层报告当前子树 保证报告的都是最大子树
+		if live {
+			dead = append(dead, deadChildren...)
+		}
+		return live
+	}
+	if !walk(n, "") && (len(n.path) > 0 || len(n.children) > 0) {
+		dead = append(dead, n.path)
+	}
+	return dead
+}
+
+// 一次addRoute的耗时
+type InsertionTiming struct {
+	Path     string
+	Duration time.Duration
+}
+
+func (r *registry) recordInsertion(path string, start time.Time) {
+	r.insertions = append(r.insertions, InsertionTiming{Path: path, Duration: time.Since(start)})
+}
+
+// 返回耗时最长的count次插入(按耗时从长到短) 只应在根结点上调用
+// 需要先通过SetOptions开启RecordInsertions
+// 耗时较长的插入通常意味着触发了较多的结点分裂
+func (n *node) SlowestInsertions(count int) []InsertionTiming {
+	if n.opts == nil {
+		return nil
+	}
+	timings := append([]InsertionTiming(nil), n.opts.insertions...)
+	sort.SliceStable(timings, func(i, j int) bool {
+		return timings[i].Duration > timings[j].Duration
+	})
+	if count < len(timings) {
+		timings = timings[:count]
+	}
+	return timings
+}
+
+// indices中的一个字符及其对应子结点的权重
+type IndexPriority struct {
+	Index    byte
+	Priority uint32
+}
+
+// 按indices的顺序(即匹配时的扫描顺序)返回每个静态子结点的首字符和权重
+// 可配合FindNode使用 如 n.FindNode("/api/").IndicesOrder()
+// 用于确认访问频繁的子结点确实排到了前面
+func (n *node) IndicesOrder() []IndexPriority {
+	order := make([]IndexPriority, len(n.indices))
+	for i := range order {
+		order[i] = IndexPriority{Index: n.indices[i], Priority: n.children[i].priority}
+	}
+	return order
+}
+
+// 设置prefix对应结点的匹配优先级
+// 默认先匹配静态子结点 prefer为true时改为先匹配通配子结点 失败后再回退到静态子结点
+// 如 /users/:id 与 /users/me 同时存在时 SetWildcardPreference("/users/", true)
+// 使 /users/me 也由 /users/:id 处理(仅当 /users/:id 无法匹配时才会使用 /users/me)
+// prefix必须恰好对应树中的一个结点 否则panic
+func (n *node) SetWildcardPreference(prefix string, prefer bool) {
+	target := n.FindNode(prefix)
+	if target == nil {
+		panic("no node found for prefix '" + prefix + "'")
+	}
+	target.preferWildcard = prefer
+}
+
+// 返回根结点的indices 即各个顶层分支的首字符
+func (n *node) RootIndices() string {
+	return n.indices
+}
+
+// 返回prefix对应结点的indices prefix必须恰好对应树中的一个结点
+func (n *node) IndicesAt(prefix string) (string, bool) {
+	target := n.FindNode(prefix)
+	if target == nil {
+		return "", false
+	}
+	return target.indices, true
+}
+
+// 添加一条只允许精确匹配的路由 适用于安全敏感的接口
+// 1. path中不能包含通配符 避免意外捕获参数
+// 2. 其他路径不会因为末尾'/'的差异被建议重定向(tsr)到该路由 LookupLenient也不会返回它
+// getValue对静态路径不做任何规范化处理 因此该路由只会被逐字节相同的路径匹配到
+func (n *node) AddExact(path string, handlers HandlersChain) {
+	if _, i, _ := findWildcard(path); i >= 0 {
+		panic("exact routes must not contain wildcards in path '" + path + "'")
+	}
+	n.addRoute(path, handlers)
+	n.FindNode(path).exact = true
+}
+
+// path是否为通过AddExact注册的路由
+func (n *node) isExactRoute(path string) bool {
+	target := n.FindNode(path)
+	return target != nil && target.exact && target.handlers != nil
+}
+
+// 一个结点的子结点当前顺序与插入顺序的差异
+type ReorderInfo struct {
+	Prefix    string //从根结点到该结点拼接得到的路径
+	Reordered bool   //当前顺序是否与插入顺序不同
+	Swaps     int    //恢复到插入顺序需要的相邻交换次数(即逆序对个数)
+}
+
+// 对每个有多个静态子结点的结点 比较其子结点的当前顺序(由incrementChildPrio按权重调整)与插入顺序
+// 用于观察权重调整实际改变了多少结点的顺序
+func (n *node) ReorderingReport() []ReorderInfo {
+	var report []ReorderInfo
+	var walk func(n *node, prefix string)
+	walk = func(n *node, prefix string) {
+		prefix += n.path
+		// 通配子结点总是位于最后(见addChild) 不参与权重排序 这里只比较静态子结点
+		if static := len(n.indices); static > 1 {
+			swaps := 0
+			for i := 0; i < static; i++ {
+				for j := i + 1; j < static; j++ {
+					if n.children[i].seq > n.children[j].seq {
+						swaps++
+					}
+				}
+			}
+			report = append(report, ReorderInfo{Prefix: prefix, Reordered: swaps > 0, Swaps: swaps})
+		}
+		for _, child := range n.children {
+			walk(child, prefix)
+		}
+	}
+	walk(n, "")
+	return report
+}
+
+// 规范化请求路径: 补全开头的'/' 去掉多余的'/'以及"."、".."
+// 与path.Clean不同的是 原路径末尾的'/'会被保留
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	cleaned := pathpkg.Clean(p)
+	if p[len(p)-1] == '/' && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// 查找带有查询参数或片段的原始路径
+// 先去掉第一个'?'或'#'及之后的内容 再规范化路径(cleanPath) 最后查找路由
+// AddExact注册的路由不接受规范化 只有原路径本身逐字节相同时才会匹配(如 //admin 不会匹配 /admin)
+// 同时返回解析后的查询参数(格式错误的部分会被忽略)
+func (n *node) LookupURL(rawPath string) (HandlersChain, Params, url.Values, bool) {
+	path, query := rawPath, ""
+	if i := strings.IndexAny(rawPath, "?#"); i >= 0 {
+		path = rawPath[:i]
+		if rawPath[i] == '?' {
+			query = rawPath[i+1:]
+			if j := strings.IndexByte(query, '#'); j >= 0 {
+				query = query[:j]
+			}
+		}
+	}
+	values, _ := url.ParseQuery(query)
+
+	cleaned := cleanPath(path)
+	value := n.LookupVerbose(cleaned, false, false)
+	if value.Handlers == nil || cleaned != path && n.isExactRoute(value.FullPath) {
+		return nil, nil, values, false
+	}
+	return value.Handlers, value.Params, values, true
+}
+
+// 按顺序取出路径中的所有通配符(含":"或"*")
+func wildcards(path string) []string {
+	var list []string
+	for {
+		wildcard, i, _ := findWildcard(path)
+		if i < 0 {
+			return list
+		}
+		list = append(list, wildcard)
+		path = path[i+len(wildcard):]
+	}
+}
+
+// 找出同一个参数结点在不同路由中被期望为不同名字的情况
+// 正常情况下这种路由在注册时就会panic(如先有/a/:x/b再注册/a/:y/c)
+// 但手动修改树(如修改参数名)后 结点上的名字与其下路由的fullPath可能不一致
+// 每条结果说明了哪个结点的参数名与哪条路由不一致
+func (n *node) ParamNameConflicts() []string {
+	var conflicts []string
+	var walk func(n *node, prefix string)
+	walk = func(n *node, prefix string) {
+		prefix += n.path
+		if n.handlers != nil {
+			actual, expected := wildcards(prefix), wildcards(n.fullPath)
+			for i := 0; i < len(actual) && i < len(expected); i++ {
+				if actual[i] != expected[i] {
+					conflicts = append(conflicts, "wildcard '"+actual[i]+"' in tree path '"+prefix+
+						"' is named '"+expected[i]+"' in route '"+n.fullPath+"'")
+				}
+			}
+		}
+		for _, child := range n.children {
+			walk(child, prefix)
+		}
+	}
+	walk(n, "")
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// gob编码时单个结点保存的内容(只包含结构相关的字段 不包含处理函数)
+type gobNode struct {
+	Path        string
+	Indices     string
+	WildChild   bool
+	NType       nodeType
+	Priority    uint32
+	FullPath    string
+	HasHandlers bool
+	Seq         uint32
+	Children    []gobNode
+}
+
+func (n *node) toGob() gobNode {
+	g := gobNode{
+		Path:        n.path,
+		Indices:     n.indices,
+		WildChild:   n.wildChild,
+		NType:       n.nType,
+		Priority:    n.priority,
+		FullPath:    n.fullPath,
+		HasHandlers: n.handlers != nil || n.unbound,
+		Seq:         n.seq,
+	}
+	for _, child := range n.children {
+		g.Children = append(g.Children, child.toGob())
+	}
+	return g
+}
+
+func (g *gobNode) toNode() *node {
+	n := &node{
+		path:      g.Path,
+		indices:   g.Indices,
+		wildChild: g.WildChild,
+		nType:     g.NType,
+		priority:  g.Priority,
+		fullPath:  g.FullPath,
+		unbound:   g.HasHandlers,
+		seq:       g.Seq,
+	}
+	for i := range g.Children {
+		n.children = append(n.children, g.Children[i].toNode())
+	}
+	return n
+}
+
+// GobEncode 将树的结构编码为gob格式 处理函数和配置项不会被编码
+// 适用于路由很多且不变的场景 启动时直接加载已经构建好的树 不必重新执行插入和分裂
+func (n *node) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(n.toGob()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode 从gob格式恢复树的结构
+// 恢复后所有路由的处理函数都为nil 需要再调用Bind按完整路径绑定处理函数
+func (n *node) GobDecode(data []byte) error {
+	var g gobNode
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&g); err != nil {
+		return err
+	}
+	*n = *g.toNode()
+	return nil
+}
+
+// 为GobDecode恢复出来的路由绑定处理函数 bind的参数为路由的完整路径
+func (n *node) Bind(bind func(fullPath string) HandlersChain) {
+	if n.unbound {
+		n.handlers = bind(n.fullPath)
+		n.unbound = false
+	}
+	for _, child := range n.children {
+		child.Bind(bind)
+	}
+}
+
+// 找出层级最深的路由 返回其完整路径及与根结点之间的边数
+// 层级相同时取完整路径字典序最小的 树中没有路由时返回空字符串和-1
+func (n *node) DeepestRoute() (template string, depth int) {
+	depth = -1
+	var walk func(n *node, d int)
+	walk = func(n *node, d int) {
+		if n.handlers != nil && (d > depth || d == depth && n.fullPath < template) {
+			template, depth = n.fullPath, d
+		}
+		for _, child := range n.children {
+			walk(child, d+1)
+		}
+	}
+	walk(n, 0)
+	return
+}
+
+// 添加路由并设置其每秒允许的请求数
+// 限流值可以通过LookupVerbose在匹配时取得 由限流中间件负责执行
+func (n *node) AddRouteWithRateLimit(path string, handlers HandlersChain, rateLimit int) {
+	n.addRoute(path, handlers)
+	n.FindNode(path).rateLimit = rateLimit
+}
+
+// 判断path能否匹配到任意一条路由(包括通过通配符匹配)
+// 不捕获参数 适合在完整查找之前快速拒绝无法路由的请求
+// 回退记录使用栈上的缓冲区 回退层数不超过8层时不分配内存
+func (n *node) Matchable(path string) bool {
+	var buf [8]skippedNode
+	skippedNodes := buf[:0]
+	return n.getValue(path, nil, &skippedNodes, false).handlers != nil
+}
+
+// 按字典序返回所有*类型路由的完整路径(保留"*name")
+// 用于检查是否注册了范围重叠、可能吞掉其他请求的*类型路由
+func (n *node) CatchAllRoutes() []string {
+	var list []string
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n.nType == catchAll && len(n.path) > 0 && n.handlers != nil {
+			list = append(list, n.fullPath)
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+	sort.Strings(list)
+	return list
+}
+
+// 根据一批实际的请求路径统计路由覆盖情况
+// hit为至少被一个请求匹配到的路由 unhit为从未被匹配到的路由(可以考虑下线) 均按字典序排列
+func (n *node) Coverage(requests []string) (hit, unhit []string) {
+	matched := make(map[string]bool)
+	skippedNodes := make([]skippedNode, 0)
+	for _, path := range requests {
+		skippedNodes = skippedNodes[:0]
+		if value := n.getValue(path, nil, &skippedNodes, false); value.handlers != nil {
+			matched[value.fullPath] = true
+		}
+	}
+
+	for _, route := range n.routes() {
+		if matched[route] {
+			hit = append(hit, route)
+		} else {
+			unhit = append(unhit, route)
+		}
+	}
+	return
+}
+
+// 按权重对所有静态子结点重新排序(通配子结点始终位于最后 不参与排序)
+func (n *node) sortChildren() {
+	for pos := 1; pos < len(n.indices); pos++ {
+		n.reorderChild(pos)
+	}
+}
+
+// 添加路由并将其权重固定为prio
+// 之后的插入不会再改变它的权重 它在兄弟结点中的位置只由固定的权重决定
+// 可以用来保证某些路由(如健康检查)始终位于扫描顺序中的特定位置
+func (n *node) AddPinned(path string, prio uint32, handlers HandlersChain) {
+	n.addRoute(path, handlers)
+	target := n.FindNode(path)
+	target.priority = prio
+	target.pinned = true
+
+	// 找到其父结点 重新排序
+	var walk func(parent *node) bool
+	walk = func(parent *node) bool {
+		for _, child := range parent.children {
+			if child == target {
+				parent.sortChildren()
+				return true
+			}
+			if walk(child) {
+				return true
+			}
+		}
+		return false
+	}
+	walk(n)
+}
+
+// 参数的类型
+type ParamKind uint8
+
+const (
+	KindParam    ParamKind = iota // ":"参数 匹配一段路径
+	KindCatchAll                  // "*"参数 匹配剩余的全部路径
+)
+
+// 路由中一个参数的描述
+type ParamSpec struct {
+	Name string
+	Kind ParamKind
+	Type string //通过AddTypedRoute声明的类型(如int) 未声明时为空字符串
+}
+
+// 按顺序返回路由模板中的所有参数 template必须是已注册的路由
+// 可用于自动绑定请求参数或生成文档
+func (n *node) ParamSchema(template string) ([]ParamSpec, bool) {
+	target := n.FindNode(template)
+	if target == nil || target.handlers == nil {
+		return nil, false
+	}
+
+	schema := []ParamSpec{}
+	for end, rest := 0, target.fullPath; ; {
+		wildcard, i, _ := findWildcard(rest)
+		if i < 0 {
+			break
+		}
+		end += i + len(wildcard)
+		rest = rest[i+len(wildcard):]
+
+		spec := ParamSpec{Name: wildcard[1:], Kind: KindParam}
+		if wildcard[0] == '*' {
+			spec.Kind = KindCatchAll
+		} else if param := n.FindNode(target.fullPath[:end]); param != nil {
+			spec.Type = param.paramType
+		}
+		schema = append(schema, spec)
+	}
+	return schema, true
+}
+
+// 深拷贝整棵树的结构 处理函数等不可复制的内容与原树共享
+// 配置项会复制一份(并关闭插入耗时记录) 对副本的修改不会影响原树
+func (n *node) clone() *node {
+	c := *n
+	if n.opts != nil {
+		opts := *n.opts
+		opts.RecordInsertions = false
+		opts.subscribers = nil
+		c.opts = &opts
+	}
+	if n.children != nil {
+		c.children = make([]*node, len(n.children))
+		for i, child := range n.children {
+			c.children[i] = child.clone()
+		}
+	}
+	return &c
+}
+
+// 检查能否添加该路由(路径是否合法、是否与已有路由冲突) 不会修改树
+func (n *node) CanAddRoute(path string) error {
+	if err := ValidatePath(path); err != nil {
+		return err
+	}
+	return n.clone().tryAddRoute(path, func() {})
+}
+
+// 一次计划中的插入
+type plannedRoute struct {
+	tree     *node
+	path     string
+	handlers HandlersChain
+}
+
+// Transaction 跨多棵树(如不同请求方法各自的树)的批量插入
+// 先通过Add记录要插入的路由 Commit时只有全部都能成功插入才会真正修改各棵树
+type Transaction struct {
+	planned []plannedRoute
+}
+
+// 记录一次要插入的路由 如果它单独插入就会失败 则直接返回错误且不记录
+func (t *Transaction) Add(tree *node, path string, handlers HandlersChain) error {
+	if err := tree.CanAddRoute(path); err != nil {
+		return err
+	}
+	t.planned = append(t.planned, plannedRoute{tree: tree, path: path, handlers: handlers})
+	return nil
+}
+
+// TransactionError Commit失败时返回的错误 包含所有插入失败的原因
+type TransactionError struct {
+	Errs []error
+}
+
+func (e *TransactionError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return "transaction aborted: " + strings.Join(msgs, "; ")
+}
+
+// 提交所有记录的插入
+// 先在每棵树的副本上按顺序插入(同一事务中的路由之间也可能冲突)
+// 只要有一条失败就返回*TransactionError 且不修改任何一棵树
+func (t *Transaction) Commit() error {
+	clones := make(map[*node]*node)
+	var errs []error
+	for _, p := range t.planned {
+		c, ok := clones[p.tree]
+		if !ok {
+			c = p.tree.clone()
+			clones[p.tree] = c
+		}
+		if err := c.tryAddRoute(p.path, p.handlers); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return &TransactionError{Errs: errs}
+	}
+
+	for _, p := range t.planned {
+		p.tree.addRoute(p.path, p.handlers)
+	}
+	t.planned = nil
+	return nil
+}
+
+// 计算每条路由最短的唯一前缀(类似git中缩写的提交号)
+// 即其他任何路由都不以它开头的最短前缀
+// 如果一条路由本身就是其他路由的前缀(如 /user 与 /user/x) 则返回完整路由
+func (n *node) UniquePrefixes() map[string]string {
+	routes := n.routes()
+	result := make(map[string]string, len(routes))
+	for i, route := range routes {
+		// 排序后 与route公共前缀最长的路由一定与它相邻
+		shared := 0
+		if i > 0 {
+			shared = longestCommonPrefix(route, routes[i-1])
+		}
+		if i+1 < len(routes) {
+			if next := longestCommonPrefix(route, routes[i+1]); next > shared {
+				shared = next
+			}
+		}
+		result[route] = route[:min(shared+1, len(route))]
+	}
+	return result
+}
+
+// 判断参数值是否满足参数结点上设置的限制
+func (n *node) acceptsParam(val string) bool {
+	return acceptsParamLen(val, n.minLen, n.maxLen) && (n.constraint == nil || n.constraint(val))
+}
+
+func acceptsParamLen(val string, minLen, maxLen int) bool {
+	if minLen > 0 && len(val) < minLen {
+		return false
+	}
+	if maxLen > 0 && len(val) > maxLen {
+		return false
+	}
+	return true
+}
+
+// 限制参数值的长度(按字节计算 包含两端) 0表示不限制
+// path为以该参数结尾的路由模板 如 SetParamLength("/item/:id", 1, 32)
+// 长度超出范围时该参数分支匹配失败 防止过长的路径段传到处理函数
+func (n *node) SetParamLength(path string, minLen, maxLen int) {
+	target := n.FindNode(path)
+	if target == nil || target.nType != param {
+		panic("no param node found for path '" + path + "'")
+	}
+	target.minLen, target.maxLen = minLen, maxLen
+}
+
+// 比较两棵树的逻辑结构是否相同 不比较权重和子结点的顺序
+// 比较内容: path、indices(排序后)、nType、wildChild、是否有处理函数 以及路由结点的fullPath
+// 中间结点的fullPath取决于是哪条路由创建了它(与插入顺序有关) 因此不参与比较
+func StructEqual(a, b *node) bool {
+	if a.path != b.path || a.nType != b.nType || a.wildChild != b.wildChild ||
+		(a.handlers == nil) != (b.handlers == nil) ||
+		len(a.indices) != len(b.indices) || len(a.children) != len(b.children) {
+		return false
+	}
+	if a.handlers != nil && a.fullPath != b.fullPath {
+		return false
+	}
+
+	// 静态子结点按首字符对应比较
+	for i := 0; i < len(a.indices); i++ {
+		j := strings.IndexByte(b.indices, a.indices[i])
+		if j < 0 || !StructEqual(a.children[i], b.children[j]) {
+			return false
+		}
+	}
+	// 其余子结点(通配子结点、参数结点后的子结点)位置固定 按顺序比较
+	for i := len(a.indices); i < len(a.children); i++ {
+		if !StructEqual(a.children[i], b.children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// FrozenTree 由可变的树"冻结"得到的只读树 只用于查找
+// 与原树相比:
+// 1. 静态子结点按首字符排序 查找时二分查找indices
+// 2. 不保存权重等只在插入时使用的字段
+// 3. 每条路由的处理函数已经与前缀中间件组合好
+// 冻结之后原树的修改(包括SetParamEnum、SetParamTransformer等注册的内容)不会影响FrozenTree
+type FrozenTree struct {
+	root *frozenNode
+	opts Options
+}
+
+type frozenNode struct {
+	path           string
+	nType          nodeType
+	indices        string        //排序后的静态子结点首字符
+	children       []*frozenNode //与indices一一对应的静态子结点
+	wild           *frozenNode   //通配子结点
+	next           *frozenNode   //参数结点后面的子结点
+	preferWildcard bool
+	minLen, maxLen int
+	trimSuffix     string
+	paramType      string
+	constraint     func(string) bool
+	tailTemplate   []string
+	fallback       *frozenNode   //下一层的兜底处理函数(见AddDepthFallback)
+	tsrPolicy      TSRPolicy     //末尾'/'策略 冻结时已经按上层结点的策略展开(不会是TSRInherit)
+	exact          bool          //通过AddExact注册 TSRLenient的子树中也不匹配只差末尾'/'的请求
+	handlers       HandlersChain //已经组合了前缀中间件
+	fullPath       string
+}
+
+// 冻结整棵树 只应在根结点上调用
+// 适用于启动后路由不再变化的服务 用可变性换取查找速度和更小的内存
+func (n *node) Freeze() *FrozenTree {
+	t := &FrozenTree{}
+	if n.opts != nil {
+		t.opts = *n.opts
+		t.opts.registry = n.opts.registry.frozenCopy()
+	}
+	t.root = n.freeze(&t.opts)
+	t.root.inheritTSRPolicy(TSRRedirect)
+	return t
+}
+
+// 复制查找时用到的注册内容 使冻结之后对原树的注册(如SetParamEnum)不影响FrozenTree
+// 插入耗时、追踪和订阅者只与插入有关 不复制
+func (r registry) frozenCopy() registry {
+	c := registry{tsrPolicies: r.tsrPolicies}
+	if r.paramTransformers != nil {
+		c.paramTransformers = make(map[string]func(string) string, len(r.paramTransformers))
+		for key, transform := range r.paramTransformers {
+			c.paramTransformers[key] = transform
+		}
+	}
+	if r.middleware != nil {
+		c.middleware = make(map[string]HandlersChain, len(r.middleware))
+		for prefix, handlers := range r.middleware {
+			c.middleware[prefix] = handlers
+		}
+	}
+	if r.paramEnums != nil {
+		c.paramEnums = make(map[string]map[string]struct{}, len(r.paramEnums))
+		for key, values := range r.paramEnums {
+			c.paramEnums[key] = make(map[string]struct{}, len(values))
+			for value := range values {
+				c.paramEnums[key][value] = struct{}{}
+			}
+		}
+	}
+	return c
+}
+
+// 将未设置末尾'/'策略的结点的策略设为上层结点的策略
+func (f *frozenNode) inheritTSRPolicy(parent TSRPolicy) {
+	if f.tsrPolicy == TSRInherit {
+		f.tsrPolicy = parent
+	}
+	for _, child := range f.children {
+		child.inheritTSRPolicy(f.tsrPolicy)
+	}
+	if f.wild != nil {
+		f.wild.inheritTSRPolicy(f.tsrPolicy)
+	}
+	if f.next != nil {
+		f.next.inheritTSRPolicy(f.tsrPolicy)
+	}
+}
+
+func (n *node) freeze(opts *Options) *frozenNode {
+	f := &frozenNode{
+		path:           n.path,
+		nType:          n.nType,
+		preferWildcard: n.preferWildcard,
+		minLen:         n.minLen,
+		maxLen:         n.maxLen,
+		trimSuffix:     n.trimSuffix,
+		paramType:      n.paramType,
+		constraint:     n.constraint,
+		tailTemplate:   n.tailTemplate,
+		tsrPolicy:      n.tsrPolicy,
+		exact:          n.exact,
+	}
+	if n.handlers != nil {
+		f.handlers = opts.withMiddleware(n.fullPath, n.handlers)
+		f.fullPath = n.fullPath
+	}
+	if n.fallback != nil {
+		f.fallback = &frozenNode{
+			handlers: opts.withMiddleware(n.fallback.fullPath, n.fallback.handlers),
+			fullPath: n.fallback.fullPath,
+		}
+	}
+
+	type indexed struct {
+		c     byte
+		child *frozenNode
+	}
+	var statics []indexed
+	for i, child := range n.children {
+		switch {
+		case i < len(n.indices):
+			statics = append(statics, indexed{n.indices[i], child.freeze(opts)})
+		case n.wildChild:
+			f.wild = child.freeze(opts)
+		default:
+			// 参数结点后面的子结点没有记录在indices中
+			f.next = child.freeze(opts)
+		}
+	}
+	sort.Slice(statics, func(i, j int) bool {
+		return statics[i].c < statics[j].c
+	})
+	for _, s := range statics {
+		f.indices += string(s.c)
+		f.children = append(f.children, s.child)
+	}
+	return f
+}
+
+// 查找路由 静态子结点优先 失败后回退到通配子结点
+// 与getValue不同的是 这里不提供末尾'/'重定向建议 而是完整地回退尝试所有分支
+// 如同时有 /cmd/whoami 与 /cmd/:tool/ 时 getValue对 /cmd/whoami/ 只给出重定向建议
+// 而这里会匹配到 /cmd/:tool/
+// 设置为TSRLenient的子树(见SetTrailingSlashPolicy)中 匹配失败后再尝试只差末尾'/'的路由
+func (t *FrozenTree) Lookup(path string) (HandlersChain, Params, bool) {
+	var params Params
+	if t.opts.BasePath != "" {
+		var ok bool
+		if path, ok = stripBasePath(t.opts.BasePath, path); !ok {
+			return nil, nil, false
+		}
+	}
+	if f := t.root.lookup(path, &params, &t.opts); f != nil {
+		return f.handlers, params, true
+	}
+	// 设置为TSRLenient的子树中 直接匹配只差末尾'/'的路由(AddExact注册的路由除外)
+	if t.opts.tsrPolicies && t.root.tsrPolicyAt(path) == TSRLenient {
+		alt := path + "/"
+		if strings.HasSuffix(path, "/") {
+			alt = path[:len(path)-1]
+		}
+		params = params[:0]
+		if f := t.root.lookup(alt, &params, &t.opts); f != nil && !f.exact {
+			return f.handlers, params, true
+		}
+	}
+	return nil, nil, false
+}
+
+// 与node.tsrPolicyAt相同 返回请求路径经过的最后一个结点的末尾'/'策略
+func (f *frozenNode) tsrPolicyAt(path string) TSRPolicy {
+	policy := TSRRedirect
+	for {
+		switch f.nType {
+		case param:
+			end := strings.IndexByte(path, '/')
+			if end < 0 {
+				return f.tsrPolicy
+			}
+			path = path[end:]
+		case catchAll:
+			return f.tsrPolicy
+		default:
+			if !strings.HasPrefix(path, f.path) {
+				if f.path == path+"/" {
+					return f.tsrPolicy
+				}
+				return policy
+			}
+			path = path[len(f.path):]
+		}
+		policy = f.tsrPolicy
+		if len(path) == 0 {
+			return policy
+		}
+
+		next := f.next
+		if i := sort.Search(len(f.indices), func(i int) bool { return f.indices[i] >= path[0] }); i < len(f.indices) && f.indices[i] == path[0] {
+			next = f.children[i]
+		} else if next == nil {
+			next = f.wild
+		}
+		if next == nil {
+			return policy
+		}
+		f = next
+	}
+}
+
+// 在以f为根的子树中匹配path 返回匹配到的路由结点
+func (f *frozenNode) lookup(path string, params *Params, opts *Options) *frozenNode {
+	switch f.nType {
+	case param:
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end == 0 && opts.RejectEmptyParams || !acceptsParamLen(path[:end], f.minLen, f.maxLen) ||
+			f.constraint != nil && !f.constraint(path[:end]) ||
+			!opts.inParamEnum(f.path[1:], path[:end]) || opts.ValidateParamTypes && !validParamType(f.paramType, path[:end]) {
+			return nil
+		}
+		*params = append(*params, Param{Key: f.path[1:], Value: f.transform(f.path[1:], path[:end], opts)})
+		path = path[end:]
+	case catchAll:
+		if len(f.path) > 0 {
+			tail := strings.TrimSuffix(path, f.trimSuffix)
+			*params = append(*params, Param{Key: f.path[2:], Value: f.transform(f.path[2:], tail, opts)})
+			if f.tailTemplate != nil {
+				appendTailParams(f.tailTemplate, tail, params)
+			}
+			return f
+		}
+		if path == "/" && f.handlers != nil {
+			return f
+		}
+	default:
+		if !strings.HasPrefix(path, f.path) {
+			return nil
+		}
+		path = path[len(f.path):]
+	}
+
+	if len(path) == 0 {
+		if f.handlers != nil {
+			return f
+		}
+		return nil
+	}
+
+	if f.next != nil {
+		return f.next.lookup(path, params, opts)
+	}
+
+	mark := len(*params)
+	if f.preferWildcard {
+		if found := f.lookupWild(path, params, opts); found != nil {
+			return found
+		}
+		*params = (*params)[:mark]
+	}
+	// 二分查找首字符相同的静态子结点
+	if i := sort.Search(len(f.indices), func(i int) bool { return f.indices[i] >= path[0] }); i < len(f.indices) && f.indices[i] == path[0] {
+		if found := f.children[i].lookup(path, params, opts); found != nil {
+			return found
+		}
+		*params = (*params)[:mark]
+	}
+	if !f.preferWildcard {
+		if found := f.lookupWild(path, params, opts); found != nil {
+			return found
+		}
+		*params = (*params)[:mark]
+	}
+	// 所有分支都匹配失败 剩余路径只有一层时使用兜底处理函数
+	if f.fallback != nil && strings.IndexByte(path, '/') < 0 {
+		*params = append(*params, Param{Key: DepthFallbackParam, Value: path})
+		return f.fallback
+	}
+	return nil
+}
+
+func (f *frozenNode) lookupWild(path string, params *Params, opts *Options) *frozenNode {
+	if f.wild == nil {
+		return nil
+	}
+	return f.wild.lookup(path, params, opts)
+}
+
+// 按配置转换参数值
+func (f *frozenNode) transform(key, val string, opts *Options) string {
+	if transform := opts.paramTransformers[key]; transform != nil {
+		return transform(val)
+	}
+	return val
+}
+
+// 一个顶层路径段下的路由统计
+type SegmentStat struct {
+	Routes int    //该路径段下注册的路由数
+	Hits   uint64 //该路径段下所有路由的命中次数之和(需要开启CountHits)
+}
+
+// 按第一个路径段(如 /api、/static)分组统计路由
+// 根路由 / 单独作为一组
+func (n *node) SegmentStats() map[string]SegmentStat {
+	stats := make(map[string]SegmentStat)
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n.handlers != nil {
+			route := n.fullPath
+			segment := route
+			if i := strings.IndexByte(route[1:], '/'); i >= 0 {
+				segment = route[:i+1]
+			}
+			stat := stats[segment]
+			stat.Routes++
+			stat.Hits += uint64(atomic.LoadUint32(&n.hits))
+			stats[segment] = stat
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+	return stats
+}
+
+// 兜底处理函数捕获的参数名
+const DepthFallbackParam = "segment"
+
+// 通过AddDepthFallback注册的兜底处理函数
+type depthFallback struct {
+	handlers HandlersChain
+	fullPath string //prefix + ":" + DepthFallbackParam
+}
+
+// 为prefix下一层注册兜底处理函数
+// 如 AddDepthFallback("/api/", h) 后 /api/ 下未注册的单层路径(如 /api/foo)由h处理 foo作为参数DepthFallbackParam
+// 与 /api/*name 不同 它不会匹配更深的路径(如 /api/foo/bar) 已注册的路由也总是优先于它
+// prefix应以'/'结尾 落在某个静态结点中间时(如只有 /api/users 时的 /api/)先像addRoute一样分裂出该结点
+// prefix不在树中时panic
+func (n *node) AddDepthFallback(prefix string, h HandlersChain) {
+	target := n.FindNode(prefix)
+	if target == nil {
+		target = n.splitAt(prefix)
+	}
+	if target == nil {
+		panic("no node found for prefix '" + prefix + "'")
+	}
+	target.fallback = &depthFallback{
+		handlers: h,
+		fullPath: prefix + ":" + DepthFallbackParam,
+	}
+}
+
+// addRoute过程中的一步结构变化
+type TraceEvent struct {
+	Op     string //walk(进入子结点) split(分裂结点) insert(插入子结点) reorder(按权重调整顺序) handle(设置处理函数)
+	Path   string //事件发生时所在结点的path
+	Detail string //可读的描述
+}
+
+func (e TraceEvent) String() string {
+	return e.Op + ": " + e.Detail
+}
+
+// 追踪期间记录一个事件 未追踪时什么都不做
+func (r *registry) traceEvent(op, path, format string, args ...interface{}) {
+	if r.trace != nil {
+		*r.trace = append(*r.trace, TraceEvent{Op: op, Path: path, Detail: fmt.Sprintf(format, args...)})
+	}
+}
+
+// 与addRoute相同 但返回插入过程中发生的结构变化(进入哪些结点、在哪里分裂、插入了哪些子结点、权重调整)
+// 用于教学和调试 对照上面addRoute中的注释观察每一步实际做了什么
+// 必须在根结点上调用
+func (n *node) addRouteTraced(path string, h HandlersChain) []TraceEvent {
+	if n.opts == nil {
+		n.opts = &Options{}
+	}
+	events := make([]TraceEvent, 0)
+	n.opts.trace = &events
+	defer func() { n.opts.trace = nil }()
+	n.addRoute(path, h)
+	return events
+}
+
+// 设置*类型参数值需要去掉的后缀 path为以*参数结尾的路由模板
+// 如 SetCatchAllSuffix("/pages/*name", ".html") 后 /pages/about.html 得到 name=/about
+// 参数值不以该后缀结尾时保持不变 如 /pages/about 仍得到 name=/about
+// 去掉后缀在解码(unescape)之后、SetParamTransformer注册的转换函数之前进行
+func (n *node) SetCatchAllSuffix(path, suffix string) {
+	target := n.FindNode(path)
+	if target == nil || target.nType != catchAll || len(target.path) == 0 || target.handlers == nil {
+		panic("no catch-all route found for path '" + path + "'")
+	}
+	target.trimSuffix = suffix
+}
+
+// 返回以n为根的子树中所有路由的完整路径(包括n自身) 按字典序排列
+// n通常来自FindNode 这样可以直接在已持有的子树上操作 而不需要再从根结点查找
+func (n *node) Descendants() []string {
+	return n.routes()
+}
+
+// Match的返回值 以后增加字段不会影响已有调用方
+type MatchResult struct {
+	Handlers HandlersChain
+	Params   Params
+	TSR      bool   //是否建议重定向到增加/去掉末尾'/'的路径
+	Template string //匹配到的路由模板(即注册时的完整路径)
+	Found    bool   //是否匹配到了路由
+}
+
+// 查找路由 以结构体形式返回结果 便于传递和扩展
+// 性能敏感的调用方仍应直接使用getValue
+func (n *node) Match(path string) MatchResult {
+	var params Params
+	skippedNodes := make([]skippedNode, 0)
+	v := n.getValue(path, &params, &skippedNodes, false)
+
+	result := MatchResult{
+		Handlers: v.handlers,
+		TSR:      v.tsr,
+		Template: v.fullPath,
+		Found:    v.handlers != nil,
+	}
+	if v.params != nil {
+		result.Params = *v.params
+	}
+	return result
+}
+
+// 检查是否存在可能同时匹配同一请求路径的两条分支 没有时返回nil
+// 1. 同一结点下同时有静态子结点和通配子结点 如 /users/:id 与 /users/me
+// 2. *类型路由的作用范围内还有其他路由 如 /files/*path 与 /files/a/b(见RoutesUnderCatchAll)
+// 存在多处时按路径字典序返回第一处
+func (n *node) AssertUnambiguous() error {
+	var conflicts []string
+	var walk func(n *node, prefix string)
+	walk = func(n *node, prefix string) {
+		prefix += n.path
+		if n.wildChild && len(n.indices) > 0 {
+			wild := n.children[len(n.children)-1]
+			conflicts = append(conflicts, fmt.Sprintf("static children '%s' and wildcard '%s' both follow prefix '%s'",
+				n.indices, wild.path, prefix))
+		}
+		for _, child := range n.children {
+			walk(child, prefix)
+		}
+	}
+	walk(n, "")
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return errors.New(conflicts[0])
+	}
+
+	overlaps := n.RoutesUnderCatchAll()
+	catchAlls := make([]string, 0, len(overlaps))
+	for route := range overlaps {
+		catchAlls = append(catchAlls, route)
+	}
+	sort.Strings(catchAlls)
+	for _, route := range catchAlls {
+		if under := overlaps[route]; len(under) > 0 {
+			return fmt.Errorf("catch-all route '%s' overlaps route '%s'", route, under[0])
+		}
+	}
+	return nil
+}
+
+// 路由变化的类型
+type RouteEventType uint8
+
+const (
+	RouteAdded RouteEventType = iota //addRoute成功添加了一条路由
+)
+
+// 一次路由变化
+type RouteEvent struct {
+	Type RouteEventType
+	Path string //路由的完整路径
+}
+
+// 每个订阅者的channel缓冲区大小
+const subscriberBuffer = 64
+
+// 订阅者列表
+type subscribers struct {
+	mu      sync.Mutex
+	chans   []chan RouteEvent
+	dropped uint64 //因缓冲区满而丢弃的事件数
+}
+
+// 依次发送给每个订阅者 不会阻塞 缓冲区满的订阅者丢弃该事件并计数
+// 持有锁期间阻塞发送会在订阅者调用Unsubscribe时死锁
+func (s *subscribers) notify(event RouteEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.chans {
+		select {
+		case ch <- event:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// 订阅路由变化 每次addRoute成功完成后会向返回的channel发送一个事件
+// 每个订阅者有各自带缓冲的channel 订阅者需要及时读取 否则缓冲区满后的事件会被丢弃(见DroppedRouteEvents)
+// 必须在根结点上调用 不再需要时通过Unsubscribe取消订阅
+func (n *node) Subscribe() <-chan RouteEvent {
+	if n.opts == nil {
+		n.opts = &Options{}
+	}
+	if n.opts.subscribers == nil {
+		n.opts.subscribers = &subscribers{}
+	}
+	s := n.opts.subscribers
+	ch := make(chan RouteEvent, subscriberBuffer)
+	s.mu.Lock()
+	s.chans = append(s.chans, ch)
+	s.mu.Unlock()
+	return ch
+}
+
+// 取消订阅并关闭对应的channel
+func (n *node) Unsubscribe(ch <-chan RouteEvent) {
+	if n.opts == nil || n.opts.subscribers == nil {
+		return
+	}
+	s := n.opts.subscribers
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, c := range s.chans {
+		if c == ch {
+			s.chans = append(s.chans[:i], s.chans[i+1:]...)
+			close(c)
+			return
+		}
+	}
+}
+
+// 返回因订阅者缓冲区满而丢弃的事件总数
+func (n *node) DroppedRouteEvents() uint64 {
+	if n.opts == nil || n.opts.subscribers == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&n.opts.subscribers.dropped)
+}
+
+// 返回能覆盖pattern作用范围的最少的已注册路由模板
+// pattern中"*"之前的部分为前缀 如 /api/* 的前缀为 /api/
+// 1. 以前缀开头的路由 以及前缀本身落在其作用范围内的*类型路由 都与pattern有交集
+// 2. 其中已被另一条路由的子树包含的(如 /api/users/:id 被 /api/users/ 包含)会被去掉 只保留最浅的那些
+// 结果按字典序排列
+func (n *node) CoveringRoutes(pattern string) []string {
+	prefix := pattern
+	if i := strings.IndexByte(pattern, '*'); i >= 0 {
+		prefix = pattern[:i]
+	}
+
+	var candidates []string
+	for _, route := range n.routes() {
+		if strings.HasPrefix(route, prefix) || coversPath(route, prefix) {
+			candidates = append(candidates, route)
+		}
+	}
+
+	var result []string
+	for _, route := range candidates {
+		covered := false
+		for _, other := range candidates {
+			if other != route && coversPath(other, route) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			result = append(result, route)
+		}
+	}
+	return result
+}
+
+// 判断路由模板route的子树是否包含path
+// *类型路由包含"*"之前的前缀下的所有路径 其他路由包含以它为前缀的下一级路径段
+func coversPath(route, path string) bool {
+	if i := strings.IndexByte(route, '*'); i >= 0 {
+		return strings.HasPrefix(path, route[:i])
+	}
+	if !strings.HasPrefix(path, route) {
+		return false
+	}
+	return len(path) == len(route) || route[len(route)-1] == '/' || path[len(route)] == '/'
+}
+
+// 去掉请求路径开头的基础路径 如base为 /app 时 /app/users 变为 /users /app 变为 /
+// 基础路径末尾的'/'会被忽略 请求路径不以基础路径开头(包括 /application 这种只有字符相同的情况)时返回false
+func stripBasePath(base, path string) (string, bool) {
+	base = strings.TrimSuffix(base, "/")
+	if !strings.HasPrefix(path, base) {
+		return "", false
+	}
+	rest := path[len(base):]
+	if rest == "" {
+		return "/", true
+	}
+	if rest[0] != '/' {
+		return "", false
+	}
+	return rest, true
+}
+
+// 整棵树各结点权重的快照
+// key为从根结点到该结点拼接得到的路径 中间结点的fullPath与插入顺序有关 因此不用作key
+// path为空的结点(如*类型路由的前置结点)拼接得到的路径与父结点相同 因此使用父结点的key加上"*"(如 /files*)
+type PrioritySnapshot map[string]uint32
+
+// 结点在PrioritySnapshot中的key prefix为拼接到该结点(包括该结点)的路径 parent为父结点的key
+func priorityKey(n *node, prefix, parent string) string {
+	if n.path == "" {
+		return parent + "*"
+	}
+	return prefix
+}
+
+// 保存当前所有结点的权重 之后可以通过RestorePriorities恢复
+func (n *node) SavePriorities() PrioritySnapshot {
+	snapshot := make(PrioritySnapshot)
+	var walk func(n *node, prefix, parent string)
+	walk = func(n *node, prefix, parent string) {
+		prefix += n.path
+		key := priorityKey(n, prefix, parent)
+		snapshot[key] = n.priority
+		for _, child := range n.children {
+			walk(child, prefix, key)
+		}
+	}
+	walk(n, "", "")
+	return snapshot
+}
+
+// 恢复快照中记录的权重 并按权重重新排列各结点的静态子结点
+// 快照之后新增的结点保持当前权重 权重固定(AddPinned)的结点不受影响
+func (n *node) RestorePriorities(snapshot PrioritySnapshot) {
+	n.restorePriorities(snapshot, "", "")
+}
+
+func (n *node) restorePriorities(snapshot PrioritySnapshot, prefix, parent string) {
+	prefix += n.path
+	key := priorityKey(n, prefix, parent)
+	if prio, ok := snapshot[key]; ok && !n.pinned {
+		n.priority = prio
+	}
+	for _, child := range n.children {
+		child.restorePriorities(snapshot, prefix, key)
+	}
+	n.sortChildren()
+}
+
+// 依次尝试paths中的每个路径 返回第一个匹配成功的结果以及该路径
+// 适用于客户端按优先级给出多个可接受路径的情况 匹配成功后不再尝试后面的路径
+func (n *node) MatchFirst(paths []string) (HandlersChain, Params, string, bool) {
+	var params Params
+	skippedNodes := make([]skippedNode, 0)
+	for _, path := range paths {
+		params = params[:0]
+		skippedNodes = skippedNodes[:0]
+		v := n.getValue(path, &params, &skippedNodes, false)
+		if v.handlers == nil {
+			continue
+		}
+		if v.params != nil {
+			return v.handlers, *v.params, path, true
+		}
+		return v.handlers, nil, path, true
+	}
+	return nil, nil, "", false
+}
+
+// 从root开始查找结点n 将沿途各结点的path拼接起来得到n的完整路径
+// 结点上没有指向父结点的指针 因此需要从root向下查找 n不在root的子树中时返回空字符串
+// 结果只取决于树的实际结构 Validate正是以它为准检查各路由结点记录的fullPath
+func (n *node) ComputeFullPath(root *node) string {
+	var find func(cur *node, prefix string) (string, bool)
+	find = func(cur *node, prefix string) (string, bool) {
+		prefix += cur.path
+		if cur == n {
+			return segmentRootPath(n, prefix), true
+		}
+		for _, child := range cur.children {
+			if full, ok := find(child, prefix); ok {
+				return full, true
+			}
+		}
+		return "", false
+	}
+	full, _ := find(root, "")
+	return full
+}
+
+// 一个参数名及使用它的路由
+type ParamUsage struct {
+	Name   string   //参数名(不含":"或"*")
+	Routes []string //使用该参数名的路由 按字典序排列
+}
+
+// 按参数名汇总所有路由中的通配符 结果按参数名排列
+// 用于检查参数命名是否一致 如同时出现 :id、:Id、:userID
+func (n *node) ParamNamingReport() []ParamUsage {
+	usage := make(map[string][]string)
+	for _, route := range n.routes() {
+		for _, wildcard := range wildcards(route) {
+			name := wildcard[1:]
+			if list := usage[name]; len(list) == 0 || list[len(list)-1] != route {
+				usage[name] = append(list, route)
+			}
+		}
+	}
+
+	report := make([]ParamUsage, 0, len(usage))
+	for name, routes := range usage {
+		report = append(report, ParamUsage{Name: name, Routes: routes})
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Name < report[j].Name
+	})
+	return report
+}
+
+// 按MaxChildren检查n能否再添加一个子结点 不能时panic
+func (o *Options) checkFanout(n *node, prefix, fullPath string) {
+	if o.MaxChildren > 0 && len(n.children) >= o.MaxChildren {
+		panic("adding path '" + fullPath + "' would exceed the limit of " +
+			strconv.Itoa(o.MaxChildren) + " children at prefix '" + prefix + "'")
+	}
+}
+
+// 返回整棵树中子结点最多的结点的子结点个数
+func (n *node) MaxFanout() int {
+	fanout := len(n.children)
+	for _, child := range n.children {
+		if f := child.MaxFanout(); f > fanout {
+			fanout = f
+		}
+	}
+	return fanout
+}
+
+// Verify中一条用例的结果
+type VerifyResult struct {
+	Path             string
+	ExpectedTemplate string
+	ActualTemplate   string //未匹配到路由时为空字符串
+	Pass             bool
+}
+
+// 按用例(请求路径 -> 期望匹配到的路由模板)逐条查找并比较 结果按请求路径排列
+// 期望模板为空字符串表示该路径不应匹配到任何路由
+func (n *node) Verify(cases map[string]string) []VerifyResult {
+	results := make([]VerifyResult, 0, len(cases))
+	for path, expected := range cases {
+		actual := n.Match(path).Template
+		results = append(results, VerifyResult{
+			Path:             path,
+			ExpectedTemplate: expected,
+			ActualTemplate:   actual,
+			Pass:             actual == expected,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Path < results[j].Path
+	})
+	return results
+}
+
+// 限制名为name的参数只能取values中的值 只应在根结点上调用
+// 如 SetParamEnum("code", []string{"en", "fr", "de"}) 后 /lang/:code 不会匹配 /lang/jp
+// 取值不在其中时该参数分支匹配失败 会回退到其他分支(如同级的静态结点)或返回未匹配
+// 比较的是未经解码(unescape)的原始值 values为空时取消限制
+func (n *node) SetParamEnum(name string, values []string) {
+	if n.opts == nil {
+		n.opts = &Options{}
+	}
+	if n.opts.paramEnums == nil {
+		n.opts.paramEnums = make(map[string]map[string]struct{})
+	}
+	if len(values) == 0 {
+		delete(n.opts.paramEnums, name)
+		return
+	}
+	set := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		set[value] = struct{}{}
+	}
+	n.opts.paramEnums[name] = set
+}
+
+// 判断参数值是否在SetParamEnum注册的允许取值中 未注册时总是允许
+func (r *registry) inParamEnum(name, val string) bool {
+	set, ok := r.paramEnums[name]
+	if !ok {
+		return true
+	}
+	_, ok = set[val]
+	return ok
+}
+
+// 为SamplePaths生成通配符的取值 最多max个
+// ":"参数有允许的取值时使用其中按字典序排列的前max个 否则使用p1、p2...
+func (r *registry) sampleValues(wildcard string, max int) []string {
+	var values []string
+	if set, ok := r.paramEnums[wildcard[1:]]; ok && wildcard[0] == ':' {
+		for v := range set {
+			values = append(values, v)
+		}
+		sort.Strings(values)
+		if len(values) > max {
+			values = values[:max]
+		}
+		return values
+	}
+	for k := 1; k <= max; k++ {
+		values = append(values, "p"+strconv.Itoa(k))
+	}
+	return values
+}
+
+// 统计查找每条路由时按indices逐个比较子结点首字符的次数 返回所有路由的平均值和最大值
+// 经过第i个(从0开始)静态子结点需要比较i+1次 经过通配子结点需要先比较完全部indices
+// 参数结点后面的子结点不需要比较 权重调整越合理 平均值越小
+func (n *node) ScanStats() (avg float64, worst int) {
+	var total, count int
+	var walk func(n *node, scans int)
+	walk = func(n *node, scans int) {
+		if n.handlers != nil {
+			total += scans
+			count++
+			if scans > worst {
+				worst = scans
+			}
+		}
+		for i, child := range n.children {
+			switch {
+			case i < len(n.indices):
+				walk(child, scans+i+1)
+			case n.wildChild:
+				walk(child, scans+len(n.indices))
+			default:
+				walk(child, scans)
+			}
+		}
+	}
+	walk(n, 0)
+	if count > 0 {
+		avg = float64(total) / float64(count)
+	}
+	return avg, worst
+}
+
+// TreeView中的一个结点
+type TreeViewEntry struct {
+	Path     string   //结点自身的path片段
+	FullPath string   //从根结点到该结点拼接得到的路径
+	Depth    int      //根结点为0
+	IsRoute  bool     //是否注册了处理函数
+	NType    nodeType //结点类型
+}
+
+// 按深度优先的顺序(即子结点的实际顺序)列出所有结点 包括没有处理函数的中间结点
+// 与只列出路由的方法不同 这里保留了层级关系 便于按Depth缩进展示成可折叠的树
+func (n *node) TreeView() []TreeViewEntry {
+	var entries []TreeViewEntry
+	var walk func(n *node, prefix string, depth int)
+	walk = func(n *node, prefix string, depth int) {
+		prefix += n.path
+		entries = append(entries, TreeViewEntry{
+			Path:     n.path,
+			FullPath: prefix,
+			Depth:    depth,
+			IsRoute:  n.handlers != nil,
+			NType:    n.nType,
+		})
+		for _, child := range n.children {
+			walk(child, prefix, depth+1)
+		}
+	}
+	walk(n, "", 0)
+	return entries
+}
+
+// 按开启CountHits后累计的命中次数重新计算权重 并重新排列各结点的子结点
+// 每条路由的权重为命中次数+1(没有命中过的路由仍然计为1) 命中次数不会被清零
+// 两次调用之间子结点的顺序不会变化 即顺序最多落后于实际流量一个调用间隔
+// 会修改树的结构 调用方需要保证期间没有并发的查找(如持有保护该树的写锁)
+func (n *node) Rebalance() {
+	weights := make(map[string]uint32)
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n.handlers != nil {
+			weight := atomic.LoadUint32(&n.hits)
+			if weight < math.MaxUint32 {
+				weight++
+			}
+			weights[n.fullPath] = weight
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+	n.SeedPriorities(weights)
+}
+
+// 假设删除路由path后 原本由它处理的请求会由哪条路由处理
+// 以path中每个通配符替换为p1得到的具体路径作为代表(与SamplePaths相同) 暂时去掉path的处理函数后重新查找
+// 没有其他路由能匹配该路径或path不是已注册的路由时ok为false
+// 查找期间会临时修改树 不能与其他查找并发执行
+func (n *node) ShadowedBy(path string) (template string, ok bool) {
+	target := n.FindNode(path)
+	if target == nil || target.handlers == nil || target.fullPath != path {
+		return "", false
+	}
+
+	handlers, lazy := target.handlers, target.lazy
+	target.handlers, target.lazy = nil, nil
+	defer func() {
+		target.handlers, target.lazy = handlers, lazy
+	}()
+
+	concrete := ""
+	for rest := path; ; {
+		wildcard, i, _ := findWildcard(rest)
+		if i < 0 {
+			concrete += rest
+			break
+		}
+		concrete += rest[:i] + "p1"
+		rest = rest[i+len(wildcard):]
+	}
+
+	if m := n.Match(concrete); m.Found {
+		return m.Template, true
+	}
+	return "", false
+}
+
+// 将所有路由转换为OpenAPI的paths骨架
+// key中的 :name 和 *name 都转换为 {name} 每个通配符对应一个必填的路径参数
+// 单棵树中没有HTTP方法的信息 因此每个路径只包含parameters 操作(get、post等)需要调用方自行补充
+func (n *node) OpenAPIPaths() map[string]interface{} {
+	paths := make(map[string]interface{})
+	for _, route := range n.routes() {
+		key := ""
+		parameters := []interface{}{}
+		for rest := route; ; {
+			wildcard, i, _ := findWildcard(rest)
+			if i < 0 {
+				key += rest
+				break
+			}
+			key += rest[:i] + "{" + wildcard[1:] + "}"
+			parameters = append(parameters, map[string]interface{}{
+				"name":     wildcard[1:],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+			rest = rest[i+len(wildcard):]
+		}
+		paths[key] = map[string]interface{}{"parameters": parameters}
+	}
+	return paths
+}
+
+// 按equal判断各路由的处理函数是否相同 相同的改为共用先遇到的那一个 返回被替换的路由个数
+// 函数值无法直接比较 因此需要调用方提供equal
+// 通过AddLazy注册、尚未构造的处理函数不参与比较
+func (n *node) InternHandlers(equal func(a, b HandlersChain) bool) int {
+	var interned []HandlersChain
+	count := 0
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n.handlers != nil && n.lazy == nil {
+			found := false
+			for _, h := range interned {
+				if equal(h, n.handlers) {
+					n.handlers = h
+					count++
+					found = true
+					break
+				}
+			}
+			if !found {
+				interned = append(interned, n.handlers)
+			}
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+	return count
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// AddTypedRoute支持的参数类型及其校验函数
+var paramTypes = map[string]func(string) bool{
+	"int": func(val string) bool {
+		_, err := strconv.ParseInt(val, 10, 64)
+		return err == nil
+	},
+	"uuid": uuidPattern.MatchString,
+	"date": func(val string) bool {
+		_, err := time.Parse("2006-01-02", val)
+		return err == nil
+	},
+}
+
+// 判断参数值是否符合声明的类型 未声明类型时总是符合
+func validParamType(typ, val string) bool {
+	if typ == "" {
+		return true
+	}
+	return paramTypes[typ](val)
+}
+
+// 添加带参数类型声明的路由 如 /user/:id<int>、/date/:d<date>
+// 类型声明会从路径中去掉 实际注册的路由为 /user/:id 类型保存在参数结点上
+// 可以通过ParamSchema取得 开启ValidateParamTypes后getValue还会按类型校验参数值
+// 支持的类型: int、uuid、date(2006-01-02) 未知类型、给*参数声明类型
+// 或同一参数结点被声明为不同类型时panic
+func (n *node) AddTypedRoute(path string, handlers HandlersChain) {
+	plain := ""
+	var typed [][2]string //以类型化参数结尾的路由前缀及其类型
+	for rest := path; ; {
+		wildcard, i, _ := findWildcard(rest)
+		if i < 0 {
+			plain += rest
+			break
+		}
+		name := wildcard
+		if j := strings.IndexByte(wildcard, '<'); j >= 0 && wildcard[len(wildcard)-1] == '>' {
+			typ := wildcard[j+1 : len(wildcard)-1]
+			if _, ok := paramTypes[typ]; !ok || wildcard[0] != ':' {
+				panic("invalid param type '" + typ + "' for '" + wildcard + "' in path '" + path + "'")
+			}
+			name = wildcard[:j]
+			typed = append(typed, [2]string{plain + rest[:i] + name, typ})
+		}
+		plain += rest[:i] + name
+		rest = rest[i+len(wildcard):]
+	}
+
+	for _, t := range typed {
+		if param := n.FindNode(t[0]); param != nil && param.paramType != "" && param.paramType != t[1] {
+			panic("param type '" + t[1] + "' in path '" + path + "' conflicts with existing type '" + param.paramType + "'")
+		}
+	}
+	n.addRoute(plain, handlers)
+	for _, t := range typed {
+		n.FindNode(t[0]).paramType = t[1]
+	}
+}
+
+// 查看请求路径在树中最远能匹配到哪里 用于排查请求为什么没有匹配到预期的路由
+// deepestPrefix为完整匹配到的最深结点(从根结点拼接得到的路径 通配符保持模板形式)
+// matchedUpTo为匹配失败前已经匹配的字节数(包括下一个结点中部分匹配的字节)
+// 会尝试所有可能的分支(静态子结点和通配子结点) 返回匹配得最远的那一个
+func (n *node) PartialMatch(path string) (deepestPrefix string, matchedUpTo int) {
+	matchedUpTo = -1
+	var walk func(n *node, path, prefix string, consumed int)
+	walk = func(n *node, path, prefix string, consumed int) {
+		switch {
+		case n.nType == param:
+			end := strings.IndexByte(path, '/')
+			if end < 0 {
+				end = len(path)
+			}
+			path, consumed = path[end:], consumed+end
+		case n.nType == catchAll && len(n.path) > 0:
+			path, consumed = "", consumed+len(path)
+		default:
+			i := longestCommonPrefix(path, n.path)
+			if i < len(n.path) {
+				if consumed+i > matchedUpTo {
+					deepestPrefix, matchedUpTo = prefix, consumed+i
+				}
+				return
+			}
+			path, consumed = path[i:], consumed+i
+		}
+		prefix += n.path
+		if consumed > matchedUpTo {
+			deepestPrefix, matchedUpTo = prefix, consumed
+		}
+		if len(path) == 0 {
+			return
+		}
+
+		for i, c := range []byte(n.indices) {
+			if c == path[0] {
+				walk(n.children[i], path, prefix, consumed)
+			}
+		}
+		if n.wildChild || n.nType == param && len(n.indices) == 0 && len(n.children) == 1 {
+			walk(n.children[len(n.children)-1], path, prefix, consumed)
+		}
+	}
+	walk(n, path, "", 0)
+	return deepestPrefix, matchedUpTo
+}
+
+// 添加路由 并为其中的":"参数注册需要满足的条件(key为参数名)
+// 条件保存在对应的参数结点上 getValue中参数值不满足条件时该参数分支匹配失败
+// 同一参数结点被多条路由注册条件时 后注册的覆盖之前的
+// constraints中的参数名不在path中(或是"*"参数)时panic 不会添加路由
+func (n *node) AddRouteConstrained(path string, handlers HandlersChain, constraints map[string]func(string) bool) {
+	prefixes := make(map[string]string, len(constraints)) //参数名 -> 以该参数结尾的路由前缀
+	for end, rest := 0, path; ; {
+		wildcard, i, _ := findWildcard(rest)
+		if i < 0 {
+			break
+		}
+		end += i + len(wildcard)
+		rest = rest[i+len(wildcard):]
+		if wildcard[0] == ':' {
+			prefixes[wildcard[1:]] = path[:end]
+		}
+	}
+	for name := range constraints {
+		if _, ok := prefixes[name]; !ok {
+			panic("constraint for unknown param '" + name + "' in path '" + path + "'")
+		}
+	}
+
+	n.addRoute(path, handlers)
+	for name, fn := range constraints {
+		n.FindNode(prefixes[name]).constraint = fn
+	}
+}
+
+// 计算各结点子结点权重分布的香农熵(以2为底) 返回所有至少有两个子结点的结点的平均值
+// 熵越低说明少数子结点占了大部分权重 按权重排序的效果越明显 熵越高说明权重分布越平均
+// 没有这样的结点时返回0
+func (n *node) BalanceScore() float64 {
+	var total float64
+	var count int
+	var walk func(n *node)
+	walk = func(n *node) {
+		if len(n.children) >= 2 {
+			var sum float64
+			for _, child := range n.children {
+				sum += float64(child.priority)
+			}
+			var entropy float64
+			for _, child := range n.children {
+				if child.priority > 0 {
+					p := float64(child.priority) / sum
+					entropy -= p * math.Log2(p)
+				}
+			}
+			total += entropy
+			count++
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
+
+// 根据已输入的部分路径返回下一段可能的取值 用于命令行风格的自动补全
+// partial最后一个'/'之后的部分视为正在输入的路径段 如 /api/v1/us 可能返回 user、users
+// 之前的各段需要与路由逐段匹配(":"参数可以匹配任意一段)
+// 下一段是通配符时返回其模板形式(如 :id、*path) 结果去重后按字典序排列
+func (n *node) NextSegments(partial string) []string {
+	typed := strings.Split(partial, "/")
+	current := typed[len(typed)-1]
+
+	set := make(map[string]bool)
+	for _, route := range n.routes() {
+		segments := strings.Split(route, "/")
+		if len(segments) < len(typed) {
+			continue
+		}
+		matched := true
+		for i := 0; i < len(typed)-1; i++ {
+			if segments[i] != typed[i] && !strings.HasPrefix(segments[i], ":") {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		next := segments[len(typed)-1]
+		if next != "" && (next[0] == ':' || next[0] == '*' || strings.HasPrefix(next, current)) {
+			set[next] = true
+		}
+	}
+
+	result := make([]string, 0, len(set))
+	for segment := range set {
+		result = append(result, segment)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// 按是否以'/'结尾将所有路由分为两组(均按字典序排列) 用于检查末尾'/'的风格是否统一
+// 根路由 / 归入withSlash
+func (n *node) TrailingSlashReport() (withSlash, withoutSlash []string) {
+	for _, route := range n.routes() {
+		if strings.HasSuffix(route, "/") {
+			withSlash = append(withSlash, route)
+		} else {
+			withoutSlash = append(withoutSlash, route)
+		}
+	}
+	return withSlash, withoutSlash
+}
+
+// 路由的缓存策略 由缓存中间件根据匹配到的路由读取并设置响应头
+type CachePolicy struct {
+	MaxAge time.Duration //Cache-Control中的max-age(0表示未设置)
+	Public bool          //是否允许共享缓存(public/private)
+	ETag   string        //固定的ETag(空字符串表示不设置)
+}
+
+// 添加路由并设置其缓存策略
+// 缓存策略可以通过LookupVerbose在匹配时取得
+func (n *node) AddRouteWithCache(path string, handlers HandlersChain, cache CachePolicy) {
+	n.addRoute(path, handlers)
+	n.FindNode(path).cache = cache
+}
+
+// 两次快照之间一个结点的权重变化
+type PriorityChange struct {
+	Path   string //快照中的key 即从根结点拼接得到的路径
+	Before uint32 //只在after中出现的结点为0
+	After  uint32 //只在before中出现的结点为0
+}
+
+// 比较两次SavePriorities的快照 返回权重发生变化的结点
+// 按变化幅度从大到小排列 幅度相同时按路径排列
+func DiffPriorities(before, after PrioritySnapshot) []PriorityChange {
+	var changes []PriorityChange
+	for path, prio := range before {
+		if prio != after[path] {
+			changes = append(changes, PriorityChange{Path: path, Before: prio, After: after[path]})
+		}
+	}
+	for path, prio := range after {
+		if _, ok := before[path]; !ok && prio != 0 {
+			changes = append(changes, PriorityChange{Path: path, After: prio})
+		}
+	}
+
+	magnitude := func(c PriorityChange) uint32 {
+		if c.After > c.Before {
+			return c.After - c.Before
+		}
+		return c.Before - c.After
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if mi, mj := magnitude(changes[i]), magnitude(changes[j]); mi != mj {
+			return mi > mj
+		}
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// 路由path尚未注册时添加并返回true 已注册时什么都不做并返回false
+// 适用于重复加载路由配置的场景 避免重复注册时panic
+// 与其他路由冲突(如通配符冲突)时仍然会panic
+func (n *node) AddRouteIfAbsent(path string, handlers HandlersChain) bool {
+	if target := n.FindNode(path); target != nil && target.handlers != nil {
+		return false
+	}
+	n.addRoute(path, handlers)
+	return true
+}
+
+// 找出缺少列表路由的"资源集合" 如注册了 /api/users/:id 和 /api/users/me 却没有注册 /api/users 或 /api/users/
+// 资源集合指从根结点拼接得到的路径以'/'结尾、子树中至少有两条路由的结点
+// 返回这些结点的路径 按字典序排列
+func (n *node) MissingIndexRoutes() []string {
+	registered := make(map[string]bool)
+	for _, route := range n.routes() {
+		registered[route] = true
+	}
+
+	var missing []string
+	var walk func(n *node, prefix string) int
+	walk = func(n *node, prefix string) int {
+		prefix += n.path
+		count := 0
+		if n.handlers != nil {
+			count++
+		}
+		for _, child := range n.children {
+			count += walk(child, prefix)
+		}
+		if n.handlers == nil && count >= 2 && strings.HasSuffix(prefix, "/") &&
+			!registered[prefix] && !registered[strings.TrimSuffix(prefix, "/")] {
+			missing = append(missing, prefix)
+		}
+		return count
+	}
+	walk(n, "")
+	sort.Strings(missing)
+	return missing
+}
+
+// getValueWith的查找参数及状态
+type lookupState struct {
+	equal    func(a, b string) bool //比较结点path与请求路径的函数 为nil时逐字节比较
+	steps    int                    //剩余的步数(每访问一个结点或回退一次算一步) 小于0表示不限制
+	exceeded bool                   //是否因为步数用完而中止
+	retry    bool                   //TSRLenient子树中的重试 不再按末尾'/'策略调整结果
+}
+
+// 是否使用自定义的比较函数
+func (st *lookupState) custom() bool {
+	return st != nil && st.equal != nil
+}
+
+// 比较结点path与请求路径中对应的部分 未设置比较函数时逐字节比较
+func (st *lookupState) same(a, b string) bool {
+	if st.custom() {
+		return st.equal(a, b)
+	}
+	return a == b
+}
+
+// 按需解码参数值 并调用SetParamTransformer注册的转换函数
+func (r *registry) paramValue(key, val string, unescape bool) string {
+	if unescape {
+		if v, err := url.QueryUnescape(val); err == nil {
+			val = v
+		}
+	}
+	if transform := r.paramTransformers[key]; transform != nil {
+		val = transform(val)
+	}
+	return val
+}
+
+// 添加路由并记录其处理函数的名称 便于在日志或路由列表中看出每条路由由哪个函数处理
+func (n *node) AddNamed(path, name string, handlers HandlersChain) {
+	n.addRoute(path, handlers)
+	n.FindNode(path).handlerName = name
+}
+
+// 返回通过AddNamed注册的所有路由及其处理函数名称(fullPath -> name)
+func (n *node) HandlerNames() map[string]string {
+	names := make(map[string]string)
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n.handlers != nil && n.handlerName != "" {
+			names[n.fullPath] = n.handlerName
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+	return names
+}
+
+// 在不修改树的前提下评估添加路由path的影响
+// wouldConflict表示addRoute是否会失败(路径不合法、与已有通配符冲突或重复注册 见CanAddRoute)
+// sharedPrefix为与path至少共享一个完整路径段的已有路由(如 /user/:id 与 /user/me 共享 /user/) 按字典序排列
+func (n *node) ImpactOf(path string) (wouldConflict bool, sharedPrefix []string) {
+	wouldConflict = n.CanAddRoute(path) != nil
+	for _, route := range n.routes() {
+		common := path[:longestCommonPrefix(route, path)]
+		if i := strings.LastIndexByte(common, '/'); i > 0 {
+			sharedPrefix = append(sharedPrefix, route)
+		}
+	}
+	return wouldConflict, sharedPrefix
+}
+
+// 树中的一条父子关系
+type Edge struct {
+	ParentFullPath string   //父结点从根结点拼接得到的路径
+	ChildFullPath  string   //子结点从根结点拼接得到的路径
+	IndexByte      byte     //子结点在父结点indices中对应的字符 通配子结点和参数结点后面的子结点为0
+	ChildNType     nodeType //子结点的类型
+}
+
+// 以边列表的形式导出整棵树 每对父子结点一条边 按深度优先的顺序排列
+// 便于导入图数据库或做其他图分析
+func (n *node) EdgeList() []Edge {
+	var edges []Edge
+	var walk func(n *node, prefix string)
+	walk = func(n *node, prefix string) {
+		prefix += n.path
+		for i, child := range n.children {
+			edge := Edge{
+				ParentFullPath: prefix,
+				ChildFullPath:  prefix + child.path,
+				ChildNType:     child.nType,
+			}
+			if i < len(n.indices) {
+				edge.IndexByte = n.indices[i]
+			}
+			edges = append(edges, edge)
+			walk(child, prefix)
+		}
+	}
+	walk(n, "")
+	return edges
+}
+
+// LookupBounded超过步数限制时返回的错误
+var ErrStepLimitExceeded = errors.New("lookup exceeded the step limit")
+
+// 限制步数的查找 每访问一个结点或回退一次算一步 超过maxSteps时中止并返回ErrStepLimitExceeded
+// 防止特殊构造的路由与请求路径组合在静态结点与通配结点之间反复回退 占用过多CPU
+// 未超过限制时结果与Match相同(同样使用getValue的查找过程)
+func (n *node) LookupBounded(path string, maxSteps int) (HandlersChain, Params, bool, error) {
+	opts := n.opts
+	if opts == nil {
+		opts = &defaultOptions
+	}
+	st := &lookupState{equal: opts.SegmentEqual, steps: maxSteps}
+	var params Params
+	skippedNodes := make([]skippedNode, 0)
+	v := n.getValueWith(path, &params, &skippedNodes, false, st)
+	if st.exceeded {
+		return nil, nil, false, ErrStepLimitExceeded
+	}
+	if v.handlers == nil {
+		return nil, nil, false, nil
+	}
+	if v.params != nil {
+		params = *v.params
+	}
+	return v.handlers, params, true, nil
+}
+
+// 一个HTTP方法及其对应的路由树(与gin中的methodTree相同)
+type methodTree struct {
+	method string
+	root   *node
+}
+
+type methodTrees []methodTree
+
+// 返回method对应的路由树的根结点 没有时返回nil
+func (trees methodTrees) get(method string) *node {
+	for _, tree := range trees {
+		if tree.method == method {
+			return tree.root
+		}
+	}
+	return nil
+}
+
+// Router 按HTTP方法分别维护一棵路由树 与gin的Engine相同
+type Router struct {
+	trees methodTrees
+}
+
+// 创建一个空的Router
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// 为method添加路由 该方法的路由树不存在时先创建
+func (r *Router) Handle(method, path string, handlers HandlersChain) {
+	if method == "" {
+		panic("HTTP method can not be empty")
+	}
+	root := r.trees.get(method)
+	if root == nil {
+		root = new(node)
+		root.fullPath = "/"
+		r.trees = append(r.trees, methodTree{method: method, root: root})
+	}
+	root.addRoute(path, handlers)
+}
+
+// 在method对应的路由树中查找path
+func (r *Router) Lookup(method, path string) (HandlersChain, Params, bool) {
+	root := r.trees.get(method)
+	if root == nil {
+		return nil, nil, false
+	}
+	m := root.Match(path)
+	return m.Handlers, m.Params, m.Found
+}
+
+// 返回每个路由模板支持的HTTP方法(按字典序排列)
+// 可用于生成接口列表 或统一生成405响应的Allow头
+func (r *Router) Endpoints() map[string][]string {
+	endpoints := make(map[string][]string)
+	for _, tree := range r.trees {
+		for _, route := range tree.root.routes() {
+			endpoints[route] = append(endpoints[route], tree.method)
+		}
+	}
+	for _, methods := range endpoints {
+		sort.Strings(methods)
+	}
+	return endpoints
+}
+
+// 为*类型路由设置拆分模板 匹配时将*参数的值按模板继续拆分为多个参数 追加在*参数之后
+// 如 SetCatchAllTemplate("/matrix/:x/:y/*rest", ":type/:id") 后
+// /matrix/1/2/user/42 得到 x=1 y=2 rest=/user/42 type=user id=42
+// 模板按'/'分段 ":"开头的段捕获对应的一段 其他段需要与之完全相同
+// 值的段数与模板不同或静态段不一致时不追加任何参数(*参数本身不受影响)
+func (n *node) SetCatchAllTemplate(route, template string) {
+	target := n.FindNode(route)
+	if target == nil || target.nType != catchAll || len(target.path) == 0 || target.handlers == nil {
+		panic("no catch-all route found for path '" + route + "'")
+	}
+	target.tailTemplate = strings.Split(strings.TrimPrefix(template, "/"), "/")
+}
+
+// 按拆分模板将*参数的值拆分后追加到params中 不匹配时不追加
+func appendTailParams(template []string, val string, params *Params) {
+	segments := strings.Split(strings.TrimPrefix(val, "/"), "/")
+	if len(segments) != len(template) {
+		return
+	}
+	for i, segment := range template {
+		if !strings.HasPrefix(segment, ":") && segment != segments[i] {
+			return
+		}
+	}
+	for i, segment := range template {
+		if strings.HasPrefix(segment, ":") {
+			*params = append(*params, Param{Key: segment[1:], Value: segments[i]})
+		}
+	}
+}
+
+// 检查一组路由的插入结果是否与插入顺序无关
+// 按原顺序和若干个随机顺序分别建树 所有树都应StructEqual(不比较权重)
+// 并且SamplePaths生成的每个路径在所有树中都匹配到相同的路由和参数
+// 用于验证结点拆分逻辑没有依赖插入顺序的问题 routes之间不能冲突
+func OrderIndependent(routes []string) bool {
+	return OrderIndependentSeeded(routes, rand.New(rand.NewSource(time.Now().UnixNano())))
+}
+
+// 与OrderIndependent相同 但随机顺序由rng生成 使用固定种子时结果可以复现(适合在测试中使用)
+func OrderIndependentSeeded(routes []string, rng *rand.Rand) bool {
+	const permutations = 8
+
+	build := func(order []int) *node {
+		tree := &node{}
+		for _, i := range order {
+			tree.addRoute(routes[i], func() {})
+		}
+		return tree
+	}
+
+	order := make([]int, len(routes))
+	for i := range order {
+		order[i] = i
+	}
+	first := build(order)
+	samples := first.SamplePaths(2)
+
+	for k := 0; k < permutations; k++ {
+		tree := build(rng.Perm(len(routes)))
+		if !StructEqual(first, tree) {
+			return false
+		}
+		for _, path := range samples {
+			a, b := first.Match(path), tree.Match(path)
+			if a.Found != b.Found || a.Template != b.Template || len(a.Params) != len(b.Params) {
+				return false
+			}
+			for i := range a.Params {
+				if a.Params[i] != b.Params[i] {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// 预览开启大小写不敏感匹配后的影响
+// 对每条路由生成的示例路径(见SamplePaths) 若另一条路由按原来的比较匹配不到它 按大小写不敏感的比较却能匹配到
+// 则开启后那条路由会开始匹配原本属于其他路由的大小写形式 返回这些路由模板(已排序)
+// 现在就已经重叠的路由(如 /a/:x 与 /a/b)与大小写无关 不会报告
+// 如同时注册 /user/:name 和 /USER/admin 时 报告 /user/:name
+func (n *node) CaseInsensitivePreview() []string {
+	// 示例路径不包含BasePath 这里用不带配置项的树查找
+	routes := n.routes()
+	all := &node{}
+	single := make([]*node, len(routes))
+	for i, route := range routes {
+		all.addRoute(route, func() {})
+		single[i] = &node{}
+		single[i].addRoute(route, func() {})
+	}
+
+	fold := &lookupState{equal: strings.EqualFold, steps: -1}
+	seen := make(map[string]bool)
+	var report []string
+	skippedNodes := make([]skippedNode, 0)
+	for _, sample := range n.SamplePaths(1) {
+		skippedNodes = skippedNodes[:0]
+		current := all.getValue(sample, nil, &skippedNodes, false)
+		if current.handlers == nil {
+			continue
+		}
+		for i, route := range routes {
+			if route == current.fullPath || seen[route] {
+				continue
+			}
+			skippedNodes = skippedNodes[:0]
+			if single[i].getValue(sample, nil, &skippedNodes, false).handlers != nil {
+				continue
+			}
+			skippedNodes = skippedNodes[:0]
+			if single[i].getValueWith(sample, nil, &skippedNodes, false, fold).handlers != nil {
+				seen[route] = true
+				report = append(report, route)
+			}
+		}
+	}
+	sort.Strings(report)
+	return report
+}
+
+// 根据结构体字段上的Route标签批量注册路由
+// Go的方法不能带标签 因此标签写在字段上 格式为 "METHOD PATH [方法名]":
+//
+//	type UserAPI struct {
+//		_      struct{} `Route:"GET /users/:id GetUser"` //注册v的GetUser方法
+//		Delete func()   `Route:"DELETE /users/:id"`      //注册字段本身的函数值
+//	}
+//
+// 方法必须是func()签名的导出方法(v为指针时包含指针接收者的方法)
+// 每个有问题的标签(格式错误、方法不存在、签名不符、路由冲突等)记录一条错误 最后合并返回
+// 其他标签正常注册
+func RegisterStruct(r *Router, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	sv := reflect.Indirect(rv)
+	if sv.Kind() != reflect.Struct {
+		return fmt.Errorf("RegisterStruct: expected a struct or pointer to struct, got %T", v)
+	}
+
+	var msgs []string
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		tag, ok := field.Tag.Lookup("Route")
+		if !ok {
+			continue
+		}
+		parts := strings.Fields(tag)
+		if len(parts) != 2 && len(parts) != 3 {
+			msgs = append(msgs, fmt.Sprintf("malformed Route tag %q", tag))
+			continue
+		}
+
+		var handler func()
+		if len(parts) == 3 {
+			method := rv.MethodByName(parts[2])
+			if !method.IsValid() {
+				msgs = append(msgs, fmt.Sprintf("Route tag %q: method %s not found", tag, parts[2]))
+				continue
+			}
+			if handler, ok = method.Interface().(func()); !ok {
+				msgs = append(msgs, fmt.Sprintf("Route tag %q: method %s must have signature func()", tag, parts[2]))
+				continue
+			}
+		} else {
+			fv := sv.Field(i)
+			if fv.Kind() != reflect.Func || !fv.CanInterface() || !fv.Type().ConvertibleTo(reflect.TypeOf(handler)) || fv.IsNil() {
+				msgs = append(msgs, fmt.Sprintf("Route tag %q: field %s must be a non-nil exported func()", tag, field.Name))
+				continue
+			}
+			handler = fv.Convert(reflect.TypeOf(handler)).Interface().(func())
+		}
+
+		if err := r.tryHandle(parts[0], parts[1], HandlersChain(handler)); err != nil {
+			msgs = append(msgs, fmt.Sprintf("Route tag %q: %v", tag, err))
+		}
+	}
+
+	if len(msgs) > 0 {
+		return errors.New("RegisterStruct: " + strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// 添加路由 将Handle中的panic转换为error返回
+func (r *Router) tryHandle(method, path string, handlers HandlersChain) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("%v", rec)
+		}
+	}()
+	r.Handle(method, path, handlers)
+	return nil
+}
+
+// 只保留routes中的路由 返回能在它们之间完成路由的最小树
+// 新树由这些路由重新插入得到 只含区分它们所需的结点(静态部分会重新压缩合并)
+// 处理函数与原树相同 权重按新树重新计算 其他路由属性和树的选项不会复制
+// routes中有未注册的路由时panic
+func (n *node) MinimalDisambiguator(routes []string) *node {
+	tree := &node{}
+	for _, route := range routes {
+		target := n.FindNode(route)
+		if target == nil || target.handlers == nil {
+			panic("no route found for path '" + route + "'")
+		}
+		tree.addRoute(route, target.handlers)
+	}
+	return tree
+}
+
+// 查找以参数结尾的路由与附近的*类型路由组成的对 每对为 [参数路由, *类型路由]
+// "附近"指*类型路由在参数所在位置只多出一段 如:
+// /download/:id 与 /download/raw/*path、/download/:id/*rest
+// 这种路由通常意图重叠 可以考虑合并为一条
+func (n *node) CatchAllOverlaps() [][2]string {
+	var paramRoutes, catchAllRoutes []string
+	for _, route := range n.routes() {
+		i := strings.LastIndexByte(route, '/')
+		switch {
+		case i >= 0 && strings.HasPrefix(route[i+1:], ":"):
+			paramRoutes = append(paramRoutes, route)
+		case i >= 0 && strings.HasPrefix(route[i+1:], "*"):
+			catchAllRoutes = append(catchAllRoutes, route)
+		}
+	}
+
+	var pairs [][2]string
+	for _, p := range paramRoutes {
+		prefix := p[:strings.LastIndexByte(p, '/')+1]
+		for _, c := range catchAllRoutes {
+			// *之前的部分去掉最后一段后应与参数之前的部分相同
+			rest := c[:strings.LastIndexByte(c, '/')]
+			if j := strings.LastIndexByte(rest, '/'); j >= 0 && rest[:j+1] == prefix {
+				pairs = append(pairs, [2]string{p, c})
+			}
+		}
+	}
+	return pairs
+}
+
+// 按顺序在多棵树中查找path 返回第一个匹配到的结果
+// 靠前的树可以覆盖靠后的树中的同名路由 而不需要合并 如 []*node{tenantTree, baseTree}
+// 参数来自匹配成功的那棵树 每棵树按自己的选项查找
+func LookupLayered(trees []*node, path string) (HandlersChain, Params, bool) {
+	for _, tree := range trees {
+		if m := tree.Match(path); m.Found {
+			return m.Handlers, m.Params, true
+		}
+	}
+	return nil, nil, false
+}
+
+// 检查一批路由之间的冲突 不涉及任何已有的树
+// 按顺序插入一棵临时树 每条插入失败的路由返回一条描述
+// 能找到与之冲突的单条前序路由时一并给出 如:
+// '/user/:name' conflicts with '/user/:id': <addRoute的错误信息>
+// 适合在注册之前检查配置文件本身是否一致
+func DetectBatchConflicts(routes []string) []string {
+	tree := &node{}
+	var added, report []string
+	for _, route := range routes {
+		if err := ValidatePath(route); err != nil {
+			report = append(report, fmt.Sprintf("'%s': %v", route, err))
+			continue
+		}
+		err := tree.tryAddRoute(route, func() {})
+		if err == nil {
+			added = append(added, route)
+			continue
+		}
+
+		msg := fmt.Sprintf("'%s': %v", route, err)
+		for _, other := range added {
+			single := &node{}
+			single.addRoute(other, func() {})
+			if single.tryAddRoute(route, func() {}) != nil {
+				msg = fmt.Sprintf("'%s' conflicts with '%s': %v", route, other, err)
+				break
+			}
+		}
+		report = append(report, msg)
+	}
+	return report
+}
+
+// 末尾'/'的处理策略
+type TSRPolicy uint8
+
+const (
+	TSRInherit  TSRPolicy = iota // 沿用上层子树的策略 都没有设置时等同于TSRRedirect
+	TSRRedirect                  // 建议重定向(设置tsr) 即默认的行为
+	TSRStrict                    // 不建议重定向 只增减末尾'/'的路径按未匹配处理
+	TSRLenient                   // 直接匹配只差末尾'/'的路由 而不是建议重定向
+)
+
+// 为prefix对应结点下的子树设置末尾'/'策略 如 /api/ 使用TSRStrict、/ui/ 使用TSRLenient
+// 查找失败时使用请求路径经过的最近一个设置了策略的结点的策略
+// prefix必须恰好对应树中的一个结点 设置为TSRInherit表示取消
+func (n *node) SetTrailingSlashPolicy(prefix string, policy TSRPolicy) {
+	target := n.FindNode(prefix)
+	if target == nil {
+		panic("no node found for prefix '" + prefix + "'")
+	}
+	target.tsrPolicy = policy
+	if n.opts == nil {
+		n.opts = &Options{}
+	}
+	n.opts.tsrPolicies = true
+}
+
+// 按请求路径所在子树的策略调整getValue的结果 只处理建议重定向的情况
+// st不为nil时重试沿用它的比较函数和剩余步数
+func (n *node) applyTSRPolicy(path string, value *nodeValue, params *Params, unescape bool, opts *Options, st *lookupState) {
+	if value.handlers != nil || !value.tsr {
+		return
+	}
+	switch n.tsrPolicyAt(path) {
+	case TSRStrict:
+		value.tsr = false
+	case TSRLenient:
+		alt := path + "/"
+		if strings.HasSuffix(path, "/") {
+			alt = path[:len(path)-1]
+		}
+		if params != nil {
+			*params = (*params)[:0]
+		}
+		skippedNodes := make([]skippedNode, 0)
+		retry := &lookupState{equal: opts.SegmentEqual, steps: -1, retry: true}
+		if st != nil {
+			retry.equal, retry.steps = st.equal, st.steps
+		}
+		v := n.getValueWith(alt, params, &skippedNodes, unescape, retry)
+		if st != nil {
+			st.steps, st.exceeded = retry.steps, retry.exceeded
+		}
+		if v.handlers != nil {
+			*value = v
+		}
+	}
+}
+
+// 沿请求路径从根结点往下走 返回经过的最近一个设置了策略的结点的策略
+// 只差末尾'/'的结点(如请求 /api 时的 /api/ 结点)也算经过
+func (n *node) tsrPolicyAt(path string) TSRPolicy {
+	policy := TSRRedirect
+	for {
+		switch n.nType {
+		case param:
+			if n.tsrPolicy != TSRInherit {
+				policy = n.tsrPolicy
+			}
+			end := strings.IndexByte(path, '/')
+			if end < 0 {
+				return policy
+			}
+			path = path[end:]
+		case catchAll:
+			return policy
+		default:
+			if !strings.HasPrefix(path, n.path) {
+				if n.path == path+"/" && n.tsrPolicy != TSRInherit {
+					policy = n.tsrPolicy
+				}
+				return policy
+			}
+			if n.tsrPolicy != TSRInherit {
+				policy = n.tsrPolicy
+			}
+			path = path[len(n.path):]
+		}
+		if len(path) == 0 {
+			return policy
+		}
+
+		var next *node
+		for i, c := range []byte(n.indices) {
+			if c == path[0] {
+				next = n.children[i]
+				break
+			}
+		}
+		if next == nil && n.wildChild {
+			next = n.children[len(n.children)-1]
+		}
+		if next == nil && n.nType == param && len(n.children) == 1 {
+			next = n.children[0]
+		}
+		if next == nil {
+			return policy
+		}
+		n = next
+	}
+}
+
+// 导出路由模板与HTTP方法的覆盖矩阵 外层key为路由模板 内层key为方法 值表示是否注册
+// 方法包括 GET、POST、PUT、PATCH、DELETE 以及Router中其他已有路由树的方法
+// 如 matrix["/users/:id"]["DELETE"] 为false 说明该资源缺少删除接口
+func (r *Router) CoverageMatrix() map[string]map[string]bool {
+	methods := []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	for _, tree := range r.trees {
+		if !stringInSlice(methods, tree.method) {
+			methods = append(methods, tree.method)
+		}
+	}
+
+	matrix := make(map[string]map[string]bool)
+	for route, registered := range r.Endpoints() {
+		row := make(map[string]bool, len(methods))
+		for _, method := range methods {
+			row[method] = stringInSlice(registered, method)
+		}
+		matrix[route] = row
+	}
+	return matrix
+}
+
+// list中是否包含s
+func stringInSlice(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
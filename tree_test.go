@@ -0,0 +1,634 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// 测试用的处理函数
+func fakeHandler() {}
+
+func TestValidatePath(t *testing.T) {
+	tests := []struct {
+		path string
+		err  error
+	}{
+		{"/", nil},
+		{"/user/:name", nil},
+		{"/user/:name/posts/:id", nil},
+		{"/src/*filepath", nil},
+		{"/files/:dir/*filepath", nil},
+		{"", ErrNoLeadingSlash},
+		{"user", ErrNoLeadingSlash},
+		{":name", ErrNoLeadingSlash},
+		{"/user/:na:me", ErrMultipleWildcards},
+		{"/user/:name*path", ErrMultipleWildcards},
+		{"/src/*file*path", ErrMultipleWildcards},
+		{"/user/:", ErrUnnamedWildcard},
+		{"/user/:/posts", ErrUnnamedWildcard},
+		{"/src/*", ErrUnnamedWildcard},
+		{"/user/:id/posts/:id", ErrDuplicateParamName},
+		{"/user/:name/*name", ErrDuplicateParamName},
+		{"/src/*filepath/x", ErrCatchAllNotAtEnd},
+		{"/src/*filepath/:id", ErrCatchAllNotAtEnd},
+		{"/src*filepath", ErrNoSlashBeforeCatchAll},
+		{"/src/a*filepath", ErrNoSlashBeforeCatchAll},
+	}
+
+	for _, tt := range tests {
+		err := ValidatePath(tt.path)
+		if tt.err == nil {
+			if err != nil {
+				t.Errorf("ValidatePath(%q) = %v, want nil", tt.path, err)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ValidatePath(%q) = %v, want %v", tt.path, err, tt.err)
+		}
+	}
+}
+
+func TestRejectEmptyParams(t *testing.T) {
+	tests := []struct {
+		reject bool
+		path   string
+		found  bool
+		name   string
+	}{
+		{false, "/user//profile", true, ""},
+		{false, "/user/gopher/profile", true, "gopher"},
+		{true, "/user//profile", false, ""},
+		{true, "/user/gopher/profile", true, "gopher"},
+	}
+
+	for _, tt := range tests {
+		tree := &node{}
+		tree.SetOptions(Options{RejectEmptyParams: tt.reject})
+		tree.addRoute("/user/:name/profile", fakeHandler)
+
+		m := tree.Match(tt.path)
+		if m.Found != tt.found {
+			t.Errorf("RejectEmptyParams=%v: Match(%q).Found = %v, want %v", tt.reject, tt.path, m.Found, tt.found)
+			continue
+		}
+		if m.Found && m.Params.ByName("name") != tt.name {
+			t.Errorf("RejectEmptyParams=%v: Match(%q) name = %q, want %q", tt.reject, tt.path, m.Params.ByName("name"), tt.name)
+		}
+	}
+}
+
+func TestReorderChildEqualPriority(t *testing.T) {
+	orders := [][]string{
+		{"/a", "/b", "/c", "/d"},
+		{"/d", "/c", "/b", "/a"},
+		{"/c", "/a", "/d", "/b"},
+		{"/b", "/d", "/a", "/c"},
+	}
+
+	var wantIndices string
+	var wantChildren []string
+	for i, routes := range orders {
+		tree := &node{}
+		for _, route := range routes {
+			tree.addRoute(route, fakeHandler)
+		}
+
+		var children []string
+		for _, child := range tree.children {
+			children = append(children, child.path)
+		}
+		if i == 0 {
+			wantIndices, wantChildren = tree.indices, children
+			if wantIndices != "abcd" {
+				t.Errorf("indices = %q, want %q", wantIndices, "abcd")
+			}
+			continue
+		}
+		if tree.indices != wantIndices {
+			t.Errorf("order %v: indices = %q, want %q", routes, tree.indices, wantIndices)
+		}
+		for j := range children {
+			if children[j] != wantChildren[j] {
+				t.Errorf("order %v: children = %v, want %v", routes, children, wantChildren)
+				break
+			}
+		}
+	}
+}
+
+func TestSetWildcardPreference(t *testing.T) {
+	tests := []struct {
+		prefer   bool
+		path     string
+		template string
+		id       string
+	}{
+		{false, "/users/me", "/users/me", ""},
+		{false, "/users/42", "/users/:id", "42"},
+		{true, "/users/me", "/users/:id", "me"},
+		{true, "/users/42", "/users/:id", "42"},
+		// :id分支无法匹配剩余路径时回退到静态分支
+		{true, "/users/me/x", "/users/me/x", ""},
+	}
+
+	for _, tt := range tests {
+		tree := &node{}
+		tree.addRoute("/users/:id", fakeHandler)
+		tree.addRoute("/users/me", fakeHandler)
+		tree.addRoute("/users/me/x", fakeHandler)
+		tree.SetWildcardPreference("/users/", tt.prefer)
+
+		m := tree.Match(tt.path)
+		if !m.Found || m.Template != tt.template {
+			t.Errorf("prefer=%v: Match(%q) = %q (found %v), want %q", tt.prefer, tt.path, m.Template, m.Found, tt.template)
+			continue
+		}
+		if id := m.Params.ByName("id"); id != tt.id {
+			t.Errorf("prefer=%v: Match(%q) id = %q, want %q", tt.prefer, tt.path, id, tt.id)
+		}
+	}
+}
+
+func TestSetParamLengthBoundaries(t *testing.T) {
+	const minLen, maxLen = 2, 4
+	tree := &node{}
+	tree.addRoute("/item/:id", fakeHandler)
+	tree.SetParamLength("/item/:id", minLen, maxLen)
+	frozen := tree.Freeze()
+
+	tests := []struct {
+		id    string
+		found bool
+	}{
+		{"a", false},     // minLen-1
+		{"ab", true},     // minLen
+		{"abcd", true},   // maxLen
+		{"abcde", false}, // maxLen+1
+	}
+
+	for _, tt := range tests {
+		path := "/item/" + tt.id
+
+		var params Params
+		skippedNodes := make([]skippedNode, 0)
+		value := tree.getValue(path, &params, &skippedNodes, false)
+		if found := value.handlers != nil; found != tt.found {
+			t.Errorf("getValue(%q) found = %v, want %v", path, found, tt.found)
+		} else if found && (*value.params).ByName("id") != tt.id {
+			t.Errorf("getValue(%q) id = %q, want %q", path, (*value.params).ByName("id"), tt.id)
+		}
+
+		_, ps, found := frozen.Lookup(path)
+		if found != tt.found {
+			t.Errorf("FrozenTree.Lookup(%q) found = %v, want %v", path, found, tt.found)
+		} else if found && ps.ByName("id") != tt.id {
+			t.Errorf("FrozenTree.Lookup(%q) id = %q, want %q", path, ps.ByName("id"), tt.id)
+		}
+	}
+}
+
+func TestAddPinnedSplit(t *testing.T) {
+	tests := []struct {
+		before []string //先注册的路由 为空时/health是根结点
+		prio   uint32
+	}{
+		{nil, 0},
+		{nil, 7},
+		{[]string{"/users"}, 0},
+		{[]string{"/users"}, 7},
+	}
+
+	for _, tt := range tests {
+		tree := &node{}
+		for _, route := range tt.before {
+			tree.addRoute(route, fakeHandler)
+		}
+		tree.AddPinned("/health", tt.prio, fakeHandler)
+		tree.addRoute("/heal", fakeHandler)
+
+		target := tree.FindNode("/health")
+		if target == nil || !target.pinned {
+			t.Errorf("before=%v pin=%d: /health is no longer pinned after split", tt.before, tt.prio)
+			continue
+		}
+		if target.priority != tt.prio {
+			t.Errorf("before=%v pin=%d: /health priority = %d after split", tt.before, tt.prio, target.priority)
+		}
+	}
+}
+
+func TestGobRoundTripSplitUnbound(t *testing.T) {
+	src := &node{}
+	src.addRoute("/users", fakeHandler)
+	data, err := src.GobEncode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree := &node{}
+	if err := tree.GobDecode(data); err != nil {
+		t.Fatal(err)
+	}
+	called := false
+	tree.addRoute("/us", func() { called = true })
+
+	var bound []string
+	tree.Bind(func(fullPath string) HandlersChain {
+		bound = append(bound, fullPath)
+		return fakeHandler
+	})
+	if len(bound) != 1 || bound[0] != "/users" {
+		t.Errorf("Bind called for %v, want [/users]", bound)
+	}
+
+	for _, path := range []string{"/users", "/us"} {
+		if m := tree.Match(path); !m.Found || m.Template != path {
+			t.Errorf("Match(%q) = %q (found %v)", path, m.Template, m.Found)
+		}
+	}
+	tree.Match("/us").Handlers()
+	if !called {
+		t.Errorf("handler registered for /us was overwritten by Bind")
+	}
+}
+
+func TestSegmentRootIsNotCatchAll(t *testing.T) {
+	tree := &node{}
+	tree.addRoute("/files/", fakeHandler)
+	tree.addRoute("/files/*path", fakeHandler)
+
+	routes := tree.CatchAllRoutes()
+	if len(routes) != 1 || routes[0] != "/files/*path" {
+		t.Errorf("CatchAllRoutes() = %v, want [/files/*path]", routes)
+	}
+	if under := tree.RoutesUnderCatchAll()["/files/*path"]; len(under) != 0 {
+		t.Errorf("RoutesUnderCatchAll() lists %v under /files/*path", under)
+	}
+	if err := tree.AssertUnambiguous(); err != nil {
+		t.Errorf("AssertUnambiguous() = %v", err)
+	}
+
+	setters := map[string]func(){
+		"SetCatchAllSuffix":   func() { tree.SetCatchAllSuffix("/files/", ".json") },
+		"SetCatchAllTemplate": func() { tree.SetCatchAllTemplate("/files/", ":name") },
+	}
+	for name, set := range setters {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s(\"/files/\") did not panic", name)
+				}
+			}()
+			set()
+		}()
+	}
+}
+
+func TestMatchableAllocs(t *testing.T) {
+	tree := &node{}
+	for _, route := range []string{"/users/:id", "/users/me", "/static/a", "/static/b", "/src/*filepath"} {
+		tree.addRoute(route, fakeHandler)
+	}
+
+	// /users/me 和 /users/me/x 需要记录回退位置 /users/me/x 还会真的回退到 /users/:id
+	for _, path := range []string{"/users/me", "/users/me/x", "/users/42", "/static/a", "/src/x/y", "/missing"} {
+		if allocs := testing.AllocsPerRun(100, func() { tree.Matchable(path) }); allocs != 0 {
+			t.Errorf("Matchable(%q) allocs = %v, want 0", path, allocs)
+		}
+	}
+}
+
+func TestCaseInsensitivePreview(t *testing.T) {
+	tests := []struct {
+		routes []string
+		want   []string
+	}{
+		{[]string{"/a/:x", "/a/b"}, nil},
+		{[]string{"/user/:name", "/USER/admin"}, []string{"/user/:name"}},
+		{[]string{"/Users", "/users"}, []string{"/Users", "/users"}},
+		{[]string{"/users", "/items"}, nil},
+	}
+
+	for _, tt := range tests {
+		tree := &node{}
+		for _, route := range tt.routes {
+			tree.addRoute(route, fakeHandler)
+		}
+		got := tree.CaseInsensitivePreview()
+		if len(got) != len(tt.want) {
+			t.Errorf("routes %v: CaseInsensitivePreview() = %v, want %v", tt.routes, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("routes %v: CaseInsensitivePreview() = %v, want %v", tt.routes, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestDepthFallbackFrozen(t *testing.T) {
+	tree := &node{}
+	tree.addRoute("/api/users", fakeHandler)
+	tree.addRoute("/api/:version/info", fakeHandler)
+	tree.AddDepthFallback("/api/", fakeHandler)
+	frozen := tree.Freeze()
+
+	tests := []struct {
+		path     string
+		found    bool
+		template string
+		segment  string
+	}{
+		{"/api/users", true, "/api/users", ""},
+		{"/api/foo", true, "/api/:segment", "foo"},
+		{"/api/v1/info", true, "/api/:version/info", ""},
+		{"/api/foo/bar", false, "", ""},
+	}
+
+	for _, tt := range tests {
+		m := tree.Match(tt.path)
+		if m.Found != tt.found || m.Template != tt.template || m.Params.ByName(DepthFallbackParam) != tt.segment {
+			t.Errorf("Match(%q) = %q %v (found %v)", tt.path, m.Template, m.Params, m.Found)
+		}
+		_, ps, found := frozen.Lookup(tt.path)
+		if found != tt.found || ps.ByName(DepthFallbackParam) != tt.segment {
+			t.Errorf("FrozenTree.Lookup(%q) = %v (found %v)", tt.path, ps, found)
+		}
+	}
+}
+
+func TestTrailingSlashPolicy(t *testing.T) {
+	tree := &node{}
+	for _, route := range []string{"/api/users", "/api/items/", "/ui/home", "/ui/p/:id/", "/other/x"} {
+		tree.addRoute(route, fakeHandler)
+	}
+	tree.AddExact("/ui/admin", fakeHandler)
+	tree.SetTrailingSlashPolicy("/api/", TSRStrict)
+	tree.SetTrailingSlashPolicy("/ui/", TSRLenient)
+	frozen := tree.Freeze()
+
+	tests := []struct {
+		path     string
+		found    bool
+		tsr      bool
+		template string
+	}{
+		{"/api/users/", false, false, ""},
+		{"/api/items", false, false, ""},
+		{"/ui/home/", true, false, "/ui/home"},
+		{"/ui/p/3", true, false, "/ui/p/:id/"},
+		{"/ui/admin/", false, false, ""},
+		{"/other/x/", false, true, ""},
+	}
+
+	for _, tt := range tests {
+		m := tree.Match(tt.path)
+		if m.Found != tt.found || m.TSR != tt.tsr || m.Template != tt.template {
+			t.Errorf("Match(%q) = %q found %v tsr %v, want %q found %v tsr %v",
+				tt.path, m.Template, m.Found, m.TSR, tt.template, tt.found, tt.tsr)
+		}
+		if _, _, found := frozen.Lookup(tt.path); found != tt.found {
+			t.Errorf("FrozenTree.Lookup(%q) found = %v, want %v", tt.path, found, tt.found)
+		}
+	}
+}
+
+func TestSamplePathsParamEnum(t *testing.T) {
+	tree := &node{}
+	tree.addRoute("/lang/:code", fakeHandler)
+	tree.addRoute("/user/:id", fakeHandler)
+	tree.SetParamEnum("code", []string{"fr", "en", "de"})
+
+	want := map[string]bool{"/lang/de": true, "/lang/en": true, "/user/p1": true, "/user/p2": true}
+	samples := tree.SamplePaths(2)
+	if len(samples) != len(want) {
+		t.Errorf("SamplePaths(2) = %v", samples)
+	}
+	for _, path := range samples {
+		if !want[path] {
+			t.Errorf("SamplePaths(2) generated unexpected %q", path)
+		}
+		if !tree.Match(path).Found {
+			t.Errorf("sample %q does not match any route", path)
+		}
+	}
+}
+
+func TestOrderIndependent(t *testing.T) {
+	const seed = 1
+	tests := []struct {
+		routes []string
+		want   bool
+	}{
+		{[]string{"/", "/users", "/us", "/src/*filepath", "/search/", "/search/:q", "/a/b/c", "/a/bc"}, true},
+		{[]string{"/user/:name", "/users", "/cmd/:tool/", "/cmd/whoami"}, true},
+		// 已知与插入顺序有关的一组路由:
+		// 先插入 /user/:name/posts 时 参数结点后面的'/'子结点由insertChild创建 不记录在indices中
+		// 先插入 /user/:name 时 参数结点还没有子结点 之后addRoute按普通静态子结点插入'/posts' 把'/'记录到indices中
+		// 两棵树能匹配相同的路径 但StructEqual比较indices时认为结构不同
+		{[]string{"/user/:name", "/user/:name/posts"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := OrderIndependentSeeded(tt.routes, rand.New(rand.NewSource(seed))); got != tt.want {
+			t.Errorf("OrderIndependentSeeded(%v) = %v, want %v", tt.routes, got, tt.want)
+		}
+	}
+}
+
+func TestAddExactNoTSR(t *testing.T) {
+	tests := []struct {
+		siblings []string
+		path     string
+	}{
+		{[]string{"/admin/x"}, "/admin/"},
+		{[]string{"/admin/x", "/admin/y"}, "/admin/"},
+		{[]string{"/admin/:id"}, "/admin/"},
+	}
+
+	for _, tt := range tests {
+		tree := &node{}
+		tree.AddExact("/admin", fakeHandler)
+		for _, route := range tt.siblings {
+			tree.addRoute(route, fakeHandler)
+		}
+
+		if m := tree.Match(tt.path); m.Found || m.TSR {
+			t.Errorf("siblings %v: Match(%q) found %v tsr %v, want neither", tt.siblings, tt.path, m.Found, m.TSR)
+		}
+		if _, _, ok := tree.LookupLenient(tt.path); ok {
+			t.Errorf("siblings %v: LookupLenient(%q) served the exact route", tt.siblings, tt.path)
+		}
+	}
+}
+
+func TestSegmentEqual(t *testing.T) {
+	tree := &node{}
+	tree.SetOptions(Options{SegmentEqual: strings.EqualFold})
+	for _, route := range []string{"/api/users", "/api/posts", "/users/:id", "/users/me", "/Docs", "/docs/intro"} {
+		tree.addRoute(route, fakeHandler)
+	}
+	tree.AddExact("/admin", fakeHandler)
+	tree.AddDepthFallback("/api/", fakeHandler)
+	tree.SetWildcardPreference("/users/", true)
+
+	tests := []struct {
+		path     string
+		found    bool
+		template string
+	}{
+		{"/API/Users", true, "/api/users"},
+		{"/api/zzz", true, "/api/:segment"},
+		{"/users/me", true, "/users/:id"},
+		{"/USERS/42", true, "/users/:id"},
+		{"/admin", true, "/admin"},
+		{"/ADMIN", false, ""},
+		{"/DOCS/INTRO", true, "/docs/intro"},
+		{"/docs", true, "/Docs"},
+	}
+
+	for _, tt := range tests {
+		m := tree.Match(tt.path)
+		if m.Found != tt.found || m.Template != tt.template {
+			t.Errorf("Match(%q) = %q (found %v), want %q (found %v)", tt.path, m.Template, m.Found, tt.template, tt.found)
+		}
+	}
+}
+
+func TestLookupBounded(t *testing.T) {
+	tree := &node{}
+	for _, route := range []string{"/api/users", "/api/posts", "/users/:id", "/users/me/x"} {
+		tree.addRoute(route, fakeHandler)
+	}
+	tree.AddDepthFallback("/api/", fakeHandler)
+
+	for _, path := range []string{"/api/foo", "/api/users", "/users/me", "/users/me/x", "/missing"} {
+		m := tree.Match(path)
+		handlers, params, found, err := tree.LookupBounded(path, 100)
+		if err != nil {
+			t.Fatalf("LookupBounded(%q) returned %v", path, err)
+		}
+		if found != m.Found || (handlers == nil) != (m.Handlers == nil) || len(params) != len(m.Params) {
+			t.Errorf("LookupBounded(%q) = (%v, %v), Match found %v with %v", path, found, params, m.Found, m.Params)
+		}
+		for i := range params {
+			if params[i] != m.Params[i] {
+				t.Errorf("LookupBounded(%q) param %v, Match param %v", path, params[i], m.Params[i])
+			}
+		}
+	}
+
+	if _, _, _, err := tree.LookupBounded("/users/me/x", 2); err != ErrStepLimitExceeded {
+		t.Errorf("LookupBounded with a small budget returned %v, want ErrStepLimitExceeded", err)
+	}
+}
+
+func TestAddPinnedParam(t *testing.T) {
+	tree := &node{}
+	tree.AddPinned("/user/:id", 5, fakeHandler)
+	tree.addRoute("/user/:id/x", fakeHandler)
+	tree.addRoute("/user/:id/y", fakeHandler)
+
+	if got := tree.FindNode("/user/:id").priority; got != 5 {
+		t.Errorf("pinned param priority = %d, want 5", got)
+	}
+}
+
+func TestSubscribeOverflow(t *testing.T) {
+	tree := &node{}
+	ch := tree.Subscribe()
+	for i := 0; i <= subscriberBuffer; i++ {
+		tree.addRoute(fmt.Sprintf("/r%d", i), fakeHandler)
+	}
+	if got := tree.DroppedRouteEvents(); got != 1 {
+		t.Errorf("DroppedRouteEvents() = %d, want 1", got)
+	}
+	tree.Unsubscribe(ch)
+	if got := len(ch); got != subscriberBuffer {
+		t.Errorf("buffered events = %d, want %d", got, subscriberBuffer)
+	}
+}
+
+func TestRestorePrioritiesCatchAll(t *testing.T) {
+	tree := &node{}
+	for _, route := range []string{"/files", "/files/*p", "/a"} {
+		tree.addRoute(route, fakeHandler)
+	}
+	before := tree.FindNode("/files").priority
+	tree.RestorePriorities(tree.SavePriorities())
+	if got := tree.FindNode("/files").priority; got != before {
+		t.Errorf("priority of /files after restore = %d, want %d", got, before)
+	}
+}
+
+func TestLookupURLExact(t *testing.T) {
+	tree := &node{}
+	tree.AddExact("/admin", fakeHandler)
+	tree.addRoute("/docs", fakeHandler)
+
+	tests := []struct {
+		path  string
+		found bool
+	}{
+		{"/admin?x=1", true},
+		{"/./admin", false},
+		{"//admin", false},
+		{"/x/../admin", false},
+		{"//docs", true},
+	}
+
+	for _, tt := range tests {
+		if _, _, _, found := tree.LookupURL(tt.path); found != tt.found {
+			t.Errorf("LookupURL(%q) found = %v, want %v", tt.path, found, tt.found)
+		}
+	}
+}
+
+func TestDepthFallbackSplit(t *testing.T) {
+	tree := &node{}
+	tree.addRoute("/api/users", fakeHandler)
+	tree.addRoute("/other", fakeHandler)
+	tree.AddDepthFallback("/api/", fakeHandler)
+
+	tests := []struct {
+		path     string
+		template string
+	}{
+		{"/api/users", "/api/users"},
+		{"/api/foo", "/api/:segment"},
+		{"/other", "/other"},
+	}
+	for _, tt := range tests {
+		if m := tree.Match(tt.path); m.Template != tt.template {
+			t.Errorf("Match(%q) = %q, want %q", tt.path, m.Template, tt.template)
+		}
+	}
+	if got := tree.FindNode("/api/").priority; got != 1 {
+		t.Errorf("priority of the split node = %d, want 1", got)
+	}
+}
+
+func TestFreezeIndependentRegistry(t *testing.T) {
+	tree := &node{}
+	tree.addRoute("/lang/:code", fakeHandler)
+	tree.SetParamEnum("code", []string{"en"})
+	tree.SetParamTransformer("code", strings.ToUpper)
+	frozen := tree.Freeze()
+
+	tree.SetParamEnum("code", []string{"fr"})
+	tree.SetParamTransformer("code", strings.ToLower)
+
+	_, params, found := frozen.Lookup("/lang/en")
+	if !found || params.ByName("code") != "EN" {
+		t.Errorf("FrozenTree.Lookup(/lang/en) = %v found %v, want code=EN", params, found)
+	}
+	if _, _, found := frozen.Lookup("/lang/fr"); found {
+		t.Errorf("FrozenTree.Lookup(/lang/fr) matched an enum value registered after Freeze")
+	}
+}